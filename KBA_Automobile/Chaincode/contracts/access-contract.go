@@ -0,0 +1,81 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// AccessContract contract for managing the orgs trusted with each role
+type AccessContract struct {
+	contractapi.Contract
+}
+
+// SetRoleMSPs replaces the orgs trusted with a role. It is reserved to the
+// admin role, so the defaults make the admins of Org1 the first to maintain
+// the registry. An admin cannot remove its own org from the admin role.
+func (a *AccessContract) SetRoleMSPs(ctx contractapi.TransactionContextInterface, role string, mspIDs []string) (*RoleMSPs, error) {
+	if err := requireRole(ctx, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if _, known := defaultRoleMSPs[role]; !known {
+		return nil, fmt.Errorf("unknown role %s, the roles are %s", role, strings.Join(allRoles, ", "))
+	}
+	updatedBy, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	trusted := &RoleMSPs{Role: role, MspIds: []string{}, UpdatedBy: updatedBy}
+	seen := make(map[string]bool, len(mspIDs))
+	for _, mspID := range mspIDs {
+		mspID = strings.TrimSpace(mspID)
+		if mspID == "" || seen[mspID] {
+			continue
+		}
+		seen[mspID] = true
+		trusted.MspIds = append(trusted.MspIds, mspID)
+	}
+	if role == RoleAdmin && !seen[updatedBy.MspId] {
+		return nil, fmt.Errorf("an admin cannot remove its own org %s from the admin role", updatedBy.MspId)
+	}
+	trusted.UpdatedAt, err = getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(roleMSPIndex, []string{role})
+	if err != nil {
+		return nil, err
+	}
+	value, _ := json.Marshal(trusted)
+	err = ctx.GetStub().PutState(key, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put to world state. %v", err)
+	}
+	err = emitRoleMSPsEvent(ctx, EventRoleMSPsSet, trusted)
+	if err != nil {
+		return nil, err
+	}
+	return trusted, nil
+}
+
+// GetRoleMSPs returns the orgs trusted with each role
+func (a *AccessContract) GetRoleMSPs(ctx contractapi.TransactionContextInterface) ([]*RoleMSPs, error) {
+	if err := requireRole(ctx, allRoles...); err != nil {
+		return nil, err
+	}
+
+	var registry []*RoleMSPs
+	for _, role := range allRoles {
+		trusted, err := getRoleMSPs(ctx, role)
+		if err != nil {
+			return nil, err
+		}
+		registry = append(registry, trusted)
+	}
+	return registry, nil
+}
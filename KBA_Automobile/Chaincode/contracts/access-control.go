@@ -0,0 +1,222 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// roleAttribute is the X.509 certificate attribute that carries the roles of
+// the submitting identity. Identities are registered with the Fabric CA using
+// e.g. `--id.attrs 'role=manufacturer:ecert'`. Several roles may be granted to
+// one identity as a comma separated list, e.g. `role=dealer,rto`.
+const roleAttribute = "role"
+
+// Roles understood by the automobile chaincode
+const (
+	RoleManufacturer  = "manufacturer"
+	RoleDealer        = "dealer"
+	RoleRTO           = "rto"
+	RoleOwner         = "owner"
+	RoleAdmin         = "admin"
+	RoleServiceCenter = "service-center"
+	RoleLender        = "lender"
+)
+
+// carReaders are the roles allowed to query cars
+var carReaders = []string{RoleManufacturer, RoleDealer, RoleRTO, RoleOwner, RoleServiceCenter, RoleLender}
+
+// allRoles are the roles the role registry knows
+var allRoles = []string{RoleManufacturer, RoleDealer, RoleRTO, RoleOwner, RoleAdmin, RoleServiceCenter, RoleLender}
+
+// roleMSPIndex is the composite key object type of the role registry in the
+// world state
+const roleMSPIndex = "roleMsps~role"
+
+// defaultRoleMSPs are the orgs trusted with each role until an admin sets them
+// with AccessContract:SetRoleMSPs. They are the orgs of the sample network,
+// which the MSP checks this chaincode used to make were written for: Org1 is
+// the manufacturer, Org2 the dealers and Org3 the RTO with the owners it
+// registers. No org is trusted with the lender role by default.
+var defaultRoleMSPs = map[string][]string{
+	RoleManufacturer:  {"Org1MSP"},
+	RoleAdmin:         {"Org1MSP"},
+	RoleDealer:        {"Org2MSP"},
+	RoleServiceCenter: {"Org2MSP"},
+	RoleRTO:           {"Org3MSP"},
+	RoleOwner:         {"Org3MSP"},
+	RoleLender:        {},
+}
+
+// RoleMSPs are the orgs whose identities may act in a role. The role attribute
+// of a certificate only counts when the certificate was issued by one of them,
+// so that the CA of another org cannot grant the role.
+type RoleMSPs struct {
+	Role      string     `json:"role"`
+	MspIds    []string   `json:"mspIds"`
+	UpdatedBy *Submitter `json:"updatedBy,omitempty" metadata:",optional"`
+	UpdatedAt string     `json:"updatedAt,omitempty" metadata:",optional"`
+}
+
+// getRoleMSPs returns the orgs trusted with a role, from the role registry or
+// the defaults
+func getRoleMSPs(ctx contractapi.TransactionContextInterface, role string) (*RoleMSPs, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(roleMSPIndex, []string{role})
+	if err != nil {
+		return nil, err
+	}
+	value, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if value == nil {
+		return &RoleMSPs{Role: role, MspIds: append([]string{}, defaultRoleMSPs[role]...)}, nil
+	}
+	var trusted RoleMSPs
+	err = json.Unmarshal(value, &trusted)
+	if err != nil {
+		return nil, err
+	}
+	return &trusted, nil
+}
+
+// getClientRoles returns the roles held by the submitting identity
+func getClientRoles(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	value, found, err := ctx.GetClientIdentity().GetAttributeValue(roleAttribute)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the %s attribute of the client identity: %v", roleAttribute, err)
+	}
+	if !found {
+		return nil, nil
+	}
+
+	var roles []string
+	for _, role := range strings.Split(value, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil
+}
+
+// hasRole returns true when the submitting identity holds the given role and
+// belongs to an org trusted with it
+func hasRole(ctx contractapi.TransactionContextInterface, role string) (bool, error) {
+	roles, err := getClientRoles(ctx)
+	if err != nil {
+		return false, err
+	}
+	held := false
+	for _, r := range roles {
+		if r == role {
+			held = true
+			break
+		}
+	}
+	if !held {
+		return false, nil
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to read the MSP ID of the client identity: %v", err)
+	}
+	trusted, err := getRoleMSPs(ctx, role)
+	if err != nil {
+		return false, err
+	}
+	for _, trustedMSP := range trusted.MspIds {
+		if trustedMSP == mspID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Submitter identifies the client identity that submitted a transaction
+type Submitter struct {
+	MspId string `json:"mspId"`
+	Name  string `json:"name"`
+}
+
+// getSubmitter returns the MSP and the name of the submitting identity. The
+// name is the enrollment ID, or the unique client ID for certificates that
+// were not issued by a Fabric CA.
+func getSubmitter(ctx contractapi.TransactionContextInterface) (*Submitter, error) {
+	identity := ctx.GetClientIdentity()
+	mspID, err := identity.GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the MSP ID of the client identity: %v", err)
+	}
+	name, found, err := identity.GetAttributeValue("hf.EnrollmentID")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the enrollment ID of the client identity: %v", err)
+	}
+	if !found || name == "" {
+		if name, err = identity.GetID(); err != nil {
+			return nil, fmt.Errorf("failed to read the ID of the client identity: %v", err)
+		}
+	}
+	return &Submitter{MspId: mspID, Name: name}, nil
+}
+
+// requireRole is the authorization check every transaction goes through. It
+// returns a forbidden error naming the missing role unless the submitting
+// identity holds at least one of the given roles and belongs to an org
+// trusted with it.
+func requireRole(ctx contractapi.TransactionContextInterface, roles ...string) error {
+	for _, role := range roles {
+		ok, err := hasRole(ctx, role)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	txName, _ := ctx.GetStub().GetFunctionAndParameters()
+	if i := strings.LastIndex(txName, ":"); i >= 0 {
+		txName = txName[i+1:]
+	}
+
+	if len(roles) == 1 {
+		return fmt.Errorf("forbidden: %s requires the %s role", txName, roles[0])
+	}
+	return fmt.Errorf("forbidden: %s requires one of the roles %s", txName, strings.Join(roles, ", "))
+}
+
+// isParty returns true when the caller is the party with the given name in
+// the given org. An enrollment ID is only unique within the CA of one org, so
+// both must match.
+func isParty(caller *Submitter, name string, mspID string) bool {
+	return caller.Name == name && caller.MspId == mspID
+}
+
+// canManageCar returns true when the caller is in charge of the car in its
+// current state: the manufacturer while it is InFactory, the dealer or owner
+// holding it, or the RTO once it is registered
+func canManageCar(ctx contractapi.TransactionContextInterface, car *Car, caller *Submitter) (bool, error) {
+	isOwner := isParty(caller, car.OwnedBy, car.OwnerMspId)
+	rules := []struct {
+		role    string
+		allowed bool
+	}{
+		{RoleManufacturer, car.Status == StatusInFactory},
+		{RoleDealer, isOwner},
+		{RoleOwner, isOwner},
+		{RoleRTO, car.Status == StatusRegistered},
+	}
+	for _, rule := range rules {
+		if !rule.allowed {
+			continue
+		}
+		ok, err := hasRole(ctx, rule.role)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,57 @@
+package contracts
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRequireRole(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke(testManufacturer, nil, "CarContract:CreateCar", "C1", "Maruti", "Alto", "Red", "fac", "2019-10-25")
+
+	l.mustFail(testDealer, nil, "forbidden: CreateCar requires the manufacturer role", "CarContract:CreateCar", "C2", "Maruti", "Alto", "Red", "fac", "2019-10-25")
+	l.mustFail(testIdentity{"Org1MSP", "", "guest"}, nil, "forbidden: ReadCar requires one of the roles", "CarContract:ReadCar", "C1")
+
+	// the CA of another org cannot grant a role
+	l.mustFail(testIdentity{"Org3MSP", RoleManufacturer, "factory"}, nil, "forbidden: CreateCar requires the manufacturer role", "CarContract:CreateCar", "C2", "Maruti", "Alto", "Red", "fac", "2019-10-25")
+
+	// one identity may hold several roles
+	l.mustInvoke(testIdentity{"Org2MSP", "dealer, service-center", "garage"}, nil, "CarContract:ReadCar", "C1")
+}
+
+func TestSetRoleMSPs(t *testing.T) {
+	l := newTestLedger(t)
+	org4Manufacturer := testIdentity{"Org4MSP", RoleManufacturer, "factory"}
+	l.mustFail(org4Manufacturer, nil, "forbidden: CreateCar requires the manufacturer role", "CarContract:CreateCar", "C1", "Maruti", "Alto", "Red", "fac", "2019-10-25")
+
+	l.mustFail(testManufacturer, nil, "forbidden: SetRoleMSPs requires the admin role", "AccessContract:SetRoleMSPs", RoleManufacturer, `["Org1MSP","Org4MSP"]`)
+	l.mustFail(testAdmin, nil, "unknown role mechanic", "AccessContract:SetRoleMSPs", "mechanic", `["Org4MSP"]`)
+	l.mustFail(testAdmin, nil, "an admin cannot remove its own org Org1MSP from the admin role", "AccessContract:SetRoleMSPs", RoleAdmin, `["Org4MSP"]`)
+
+	l.mustInvoke(testAdmin, nil, "AccessContract:SetRoleMSPs", RoleManufacturer, `["Org4MSP"," Org4MSP",""]`)
+	if l.lastEvent() != EventRoleMSPsSet {
+		t.Fatalf("event = %s, want %s", l.lastEvent(), EventRoleMSPsSet)
+	}
+	l.mustInvoke(org4Manufacturer, nil, "CarContract:CreateCar", "C1", "Maruti", "Alto", "Red", "fac", "2019-10-25")
+	l.mustFail(testManufacturer, nil, "forbidden: CreateCar requires the manufacturer role", "CarContract:CreateCar", "C2", "Maruti", "Alto", "Red", "fac", "2019-10-25")
+
+	var registry []*RoleMSPs
+	if err := json.Unmarshal([]byte(l.mustInvoke(testDealer, nil, "AccessContract:GetRoleMSPs")), &registry); err != nil {
+		t.Fatal(err)
+	}
+	for _, trusted := range registry {
+		switch trusted.Role {
+		case RoleManufacturer:
+			if len(trusted.MspIds) != 1 || trusted.MspIds[0] != "Org4MSP" || trusted.UpdatedBy == nil || trusted.UpdatedBy.Name != testAdmin.name {
+				t.Fatalf("manufacturer orgs = %+v, want Org4MSP set by the admin", trusted)
+			}
+		case RoleDealer:
+			if len(trusted.MspIds) != 1 || trusted.MspIds[0] != "Org2MSP" || trusted.UpdatedBy != nil {
+				t.Fatalf("dealer orgs = %+v, want the default Org2MSP", trusted)
+			}
+		}
+	}
+	if len(registry) != len(allRoles) {
+		t.Fatalf("GetRoleMSPs lists %d roles, want %d", len(registry), len(allRoles))
+	}
+}
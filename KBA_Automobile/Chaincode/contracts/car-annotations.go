@@ -0,0 +1,161 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// carAnnotationIndex is the composite key object type of the annotations of a
+// car: the records other parties than its owner keep about it. They live
+// under their own keys, next to the car, so that they carry no key-level
+// endorsement policy and the manufacturer, the service centers and the
+// lenders can record them without a peer of the owning org. The liens are
+// the exception, as they lock the car: their key has the key-level policy of
+// the car, see setLienEndorsement. readCar merges them back into the car.
+const carAnnotationIndex = "annotation~carId~kind"
+
+// Kinds of car annotations
+const (
+	annotationRecalls  = "recalls"
+	annotationOdometer = "odometer"
+	annotationLiens    = "liens"
+)
+
+var annotationKinds = []string{annotationRecalls, annotationOdometer, annotationLiens}
+
+// recallAnnotation holds the recalls of a car
+type recallAnnotation struct {
+	Recalls []*CarRecall `json:"recalls"`
+}
+
+// odometerAnnotation holds the odometer timeline of a car
+type odometerAnnotation struct {
+	Odometer          int                `json:"odometer"`
+	SuspectedRollback bool               `json:"suspectedRollback"`
+	Readings          []*OdometerReading `json:"readings"`
+}
+
+// lienAnnotation holds the liens of a car
+type lienAnnotation struct {
+	Lien        *Lien   `json:"lien,omitempty"`
+	PendingLien *Lien   `json:"pendingLien,omitempty"`
+	History     []*Lien `json:"history"`
+}
+
+// annotationValue returns the part of the car kept under the annotation key
+// of the given kind
+func annotationValue(car *Car, kind string) interface{} {
+	switch kind {
+	case annotationRecalls:
+		return &recallAnnotation{Recalls: car.Recalls}
+	case annotationOdometer:
+		return &odometerAnnotation{Odometer: car.Odometer, SuspectedRollback: car.SuspectedRollback, Readings: car.OdometerReadings}
+	default:
+		return &lienAnnotation{Lien: car.Lien, PendingLien: car.PendingLien, History: car.LienHistory}
+	}
+}
+
+// applyAnnotation sets the part of the car kept under the annotation key of
+// the given kind from the stored value
+func applyAnnotation(car *Car, kind string, value []byte) error {
+	var err error
+	switch kind {
+	case annotationRecalls:
+		var recalls recallAnnotation
+		err = json.Unmarshal(value, &recalls)
+		car.Recalls = recalls.Recalls
+	case annotationOdometer:
+		var odometer odometerAnnotation
+		err = json.Unmarshal(value, &odometer)
+		car.Odometer = odometer.Odometer
+		car.SuspectedRollback = odometer.SuspectedRollback
+		car.OdometerReadings = odometer.Readings
+	default:
+		var liens lienAnnotation
+		err = json.Unmarshal(value, &liens)
+		car.Lien = liens.Lien
+		car.PendingLien = liens.PendingLien
+		car.LienHistory = liens.History
+	}
+	if err != nil {
+		return fmt.Errorf("could not unmarshal the %s of car %s", kind, car.CarId)
+	}
+	return nil
+}
+
+// withoutAnnotations returns a copy of the car without its annotations, as it
+// is stored under the car key
+func withoutAnnotations(car *Car) *Car {
+	stored := *car
+	stored.Recalls = nil
+	stored.Odometer = 0
+	stored.SuspectedRollback = false
+	stored.OdometerReadings = nil
+	stored.Lien = nil
+	stored.PendingLien = nil
+	stored.LienHistory = nil
+	return &stored
+}
+
+// hasAnnotations returns true when the car holds any annotation. Cars written
+// by earlier versions of this chaincode kept them in the car itself.
+func hasAnnotations(car *Car) bool {
+	return len(car.Recalls) > 0 || car.Odometer != 0 || car.SuspectedRollback || len(car.OdometerReadings) > 0 ||
+		car.Lien != nil || car.PendingLien != nil || len(car.LienHistory) > 0
+}
+
+// loadAnnotations merges the annotations stored under their own keys into the
+// car. An annotation without a key of its own is left as the car holds it.
+func loadAnnotations(ctx contractapi.TransactionContextInterface, car *Car) error {
+	for _, kind := range annotationKinds {
+		key, err := ctx.GetStub().CreateCompositeKey(carAnnotationIndex, []string{car.CarId, kind})
+		if err != nil {
+			return err
+		}
+		value, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if value == nil {
+			continue
+		}
+		if err := applyAnnotation(car, kind, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putCarAnnotation writes the annotation of the given kind of the car. Unlike
+// putCar it leaves the car key alone, so it needs no endorsement of the org
+// owning the car, except for the liens.
+func putCarAnnotation(ctx contractapi.TransactionContextInterface, car *Car, kind string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(carAnnotationIndex, []string{car.CarId, kind})
+	if err != nil {
+		return err
+	}
+	value, _ := json.Marshal(annotationValue(car, kind))
+	if err := ctx.GetStub().PutState(key, value); err != nil {
+		return fmt.Errorf("failed to put to world state. %v", err)
+	}
+	if kind == annotationLiens {
+		return setLienEndorsement(ctx, car.CarId, car.OwnerMspId)
+	}
+	return nil
+}
+
+// deleteCarAnnotations removes every annotation of the car
+func deleteCarAnnotations(ctx contractapi.TransactionContextInterface, carID string) error {
+	for _, kind := range annotationKinds {
+		key, err := ctx.GetStub().CreateCompositeKey(carAnnotationIndex, []string{carID, kind})
+		if err != nil {
+			return err
+		}
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// maxBatchSize caps the number of cars handled by one batch transaction
+const maxBatchSize = 500
+
+// CarInput describes a car to be created. The VIN is optional.
+type CarInput struct {
+	CarId             string `json:"carId"`
+	Vin               string `json:"vin,omitempty" metadata:",optional"`
+	Make              string `json:"make"`
+	Model             string `json:"model"`
+	Color             string `json:"color"`
+	ManufacturerName  string `json:"manufacturerName"`
+	DateOfManufacture string `json:"dateOfManufacture"`
+}
+
+// BatchItemResult is the outcome for one car of a batch
+type BatchItemResult struct {
+	CarId   string `json:"carId"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty" metadata:",optional"`
+}
+
+// BatchItemError is one car of a batch that failed validation. The index is
+// the position of the car in the batch, starting at 0.
+type BatchItemError struct {
+	Index int    `json:"index"`
+	CarId string `json:"carId"`
+	Error string `json:"error"`
+}
+
+// BatchError rejects a batch. Its message carries the failed items as a JSON
+// object after the prefix, so that clients can report them item by item.
+type BatchError struct {
+	Failures []*BatchItemError `json:"failures"`
+}
+
+// batchErrorPrefix starts the message of every BatchError
+const batchErrorPrefix = "batch rejected, no cars were created: "
+
+func (e *BatchError) Error() string {
+	bytes, _ := json.Marshal(e)
+	return batchErrorPrefix + string(bytes)
+}
+
+// ReadCarsResult holds the cars found by ReadCars and the IDs that were not
+type ReadCarsResult struct {
+	Found   map[string]*Car `json:"found"`
+	Missing []string        `json:"missing"`
+}
+
+// CreateCarsBatch creates all the given cars in a single transaction. The
+// batch is all-or-nothing: when any car fails validation the transaction is
+// rejected with a BatchError listing the index, car ID and error of every
+// failed item, and no car is created.
+// Cars created in a batch are never matched to orders automatically.
+func (c *CarContract) CreateCarsBatch(ctx contractapi.TransactionContextInterface, cars []*CarInput) ([]*BatchItemResult, error) {
+	if err := requireRole(ctx, RoleManufacturer); err != nil {
+		return nil, err
+	}
+	if len(cars) == 0 || len(cars) > maxBatchSize {
+		return nil, fmt.Errorf("a batch must hold between 1 and %d cars, got %d", maxBatchSize, len(cars))
+	}
+
+	var results []*BatchItemResult
+	batchErr := &BatchError{}
+	var carIDs []string
+	seen := make(map[string]bool, len(cars))
+
+	for i, input := range cars {
+		result := &BatchItemResult{CarId: input.CarId}
+		results = append(results, result)
+
+		// writes are not visible to reads within the same transaction, so
+		// duplicates inside the batch have to be caught here
+		if seen[input.CarId] {
+			result.Error = fmt.Sprintf("the car, %s appears more than once in the batch", input.CarId)
+		} else if _, err := createCar(ctx, input, false); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Success = true
+			carIDs = append(carIDs, input.CarId)
+		}
+		seen[input.CarId] = true
+
+		if !result.Success {
+			batchErr.Failures = append(batchErr.Failures, &BatchItemError{Index: i, CarId: input.CarId, Error: result.Error})
+		}
+	}
+
+	if len(batchErr.Failures) > 0 {
+		return nil, batchErr
+	}
+
+	header, err := newEventHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = setEvent(ctx, EventCarsCreated, CarsBatchEvent{EventHeader: header, CarIds: carIDs})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ReadCars retrieves the cars with the given IDs, listing the IDs that do not exist
+func (c *CarContract) ReadCars(ctx contractapi.TransactionContextInterface, carIDs []string) (*ReadCarsResult, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+	if len(carIDs) > maxBatchSize {
+		return nil, fmt.Errorf("at most %d cars can be read at once, got %d", maxBatchSize, len(carIDs))
+	}
+
+	result := &ReadCarsResult{Found: map[string]*Car{}, Missing: []string{}}
+	for _, carID := range carIDs {
+		exists, err := carExists(ctx, carID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			result.Missing = append(result.Missing, carID)
+			continue
+		}
+		car, err := readCar(ctx, carID)
+		if err != nil {
+			return nil, err
+		}
+		result.Found[carID] = car
+	}
+	return result, nil
+}
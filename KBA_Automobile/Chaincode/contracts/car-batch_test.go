@@ -0,0 +1,52 @@
+package contracts
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCreateCarsBatch(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke(testManufacturer, nil, "CarContract:CreateCar", "C1", "Maruti", "Alto", "Red", "fac", "2019-10-25")
+
+	batch := `[
+		{"carId":"C2","make":"Maruti","model":"Alto","color":"Red","manufacturerName":"fac","dateOfManufacture":"2019-10-25"},
+		{"carId":"C1","make":"Maruti","model":"Alto","color":"Red","manufacturerName":"fac","dateOfManufacture":"2019-10-25"},
+		{"carId":"C2","make":"Maruti","model":"Alto","color":"Red","manufacturerName":"fac","dateOfManufacture":"2019-10-25"},
+		{"carId":"C3","make":"Maruti","model":"Alto","color":"Red","manufacturerName":"fac","dateOfManufacture":"2019-10-25"}
+	]`
+	_, err := l.invoke(testManufacturer, nil, "CarContract:CreateCarsBatch", batch)
+	if err == nil || !strings.HasPrefix(err.Error(), batchErrorPrefix) {
+		t.Fatalf("CreateCarsBatch = %v, want a batch error", err)
+	}
+	var rejected BatchError
+	failures := strings.TrimPrefix(err.Error(), batchErrorPrefix)
+	if err := json.Unmarshal([]byte(failures), &rejected); err != nil {
+		t.Fatalf("the failures %s are not JSON: %v", failures, err)
+	}
+	want := []BatchItemError{
+		{Index: 1, CarId: "C1", Error: "the car, C1 already exists"},
+		{Index: 2, CarId: "C2", Error: "the car, C2 appears more than once in the batch"},
+	}
+	if len(rejected.Failures) != len(want) {
+		t.Fatalf("failures = %d, want %d", len(rejected.Failures), len(want))
+	}
+	for i, failure := range rejected.Failures {
+		if *failure != want[i] {
+			t.Fatalf("failure %d = %+v, want %+v", i, *failure, want[i])
+		}
+	}
+	if _, ok := l.state["C2"]; ok {
+		t.Fatal("a rejected batch created car C2")
+	}
+
+	var results []*BatchItemResult
+	result := l.mustInvoke(testManufacturer, nil, "CarContract:CreateCarsBatch", `[{"carId":"C2","make":"Maruti","model":"Alto","color":"Red","manufacturerName":"fac","dateOfManufacture":"2019-10-25"}]`)
+	if err := json.Unmarshal([]byte(result), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].Success || l.readCar("C2").Status != StatusInFactory {
+		t.Fatalf("CreateCarsBatch = %s, want car C2 created", result)
+	}
+}
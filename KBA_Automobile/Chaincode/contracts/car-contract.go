@@ -3,10 +3,15 @@ package contracts
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"couchquery"
+
 	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
 	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
 )
 
 // CarContract contract for managing CRUD for Car
@@ -15,25 +20,52 @@ type CarContract struct {
 }
 
 type Car struct {
-	AssetType         string `json:"assetType"`
-	CarId             string `json:"carId"`
-	Color             string `json:"color"`
-	DateOfManufacture string `json:"dateOfManufacture"`
-	Make              string `json:"make"`
-	Model             string `json:"model"`
-	OwnedBy           string `json:"ownedBy"`
-	Status            string `json:"status"`
-}
-
-type HistoryQueryResult struct {
-	Record    *Car   `json:"record"`
-	TxId      string `json:"txId"`
-	Timestamp string `json:"timestamp"`
-	IsDelete  bool   `json:"isDelete"`
+	AssetType          string    `json:"assetType"`
+	CarId              string    `json:"carId"`
+	Color              string    `json:"color"`
+	DateOfManufacture  string    `json:"dateOfManufacture"`
+	Make               string    `json:"make"`
+	Model              string    `json:"model"`
+	OwnedBy            string    `json:"ownedBy"`
+	Status             CarStatus `json:"status"`
+	Vin                string    `json:"vin,omitempty" metadata:",optional"`
+	ManufacturerCode   string    `json:"manufacturerCode,omitempty" metadata:",optional"`
+	ModelYear          int       `json:"modelYear,omitempty" metadata:",optional"`
+	RegistrationNumber string    `json:"registrationNumber,omitempty" metadata:",optional"`
+	RegistrationDate   string    `json:"registrationDate,omitempty" metadata:",optional"`
+
+	PreviousRegistrations []*RegistrationRecord `json:"previousRegistrations,omitempty" metadata:",optional"`
+
+	OrderId         string             `json:"orderId,omitempty" metadata:",optional"`
+	MatchDecision   *MatchDecision     `json:"matchDecision,omitempty" metadata:",optional"`
+	OwnerMspId      string             `json:"ownerMspId,omitempty" metadata:",optional"`
+	OwnershipChain  []*OwnershipRecord `json:"ownershipChain,omitempty" metadata:",optional"`
+	PendingTransfer *OwnershipTransfer `json:"pendingTransfer,omitempty" metadata:",optional"`
+	Scrapping       *ScrapRecord       `json:"scrapping,omitempty" metadata:",optional"`
+
+	Lien        *Lien   `json:"lien,omitempty" metadata:",optional"`
+	PendingLien *Lien   `json:"pendingLien,omitempty" metadata:",optional"`
+	LienHistory []*Lien `json:"lienHistory,omitempty" metadata:",optional"`
+
+	Odometer          int                `json:"odometer,omitempty" metadata:",optional"`
+	SuspectedRollback bool               `json:"suspectedRollback,omitempty" metadata:",optional"`
+	OdometerReadings  []*OdometerReading `json:"odometerReadings,omitempty" metadata:",optional"`
+
+	Recalls []*CarRecall `json:"recalls,omitempty" metadata:",optional"`
+
+	ModifiedBy *Submitter   `json:"modifiedBy,omitempty" metadata:",optional"`
+	Updates    []*CarUpdate `json:"updates,omitempty" metadata:",optional"`
 }
 
 // CarExists returns true when asset with given ID exists in world state
 func (c *CarContract) CarExists(ctx contractapi.TransactionContextInterface, carID string) (bool, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return false, err
+	}
+	return carExists(ctx, carID)
+}
+
+func carExists(ctx contractapi.TransactionContextInterface, carID string) (bool, error) {
 	data, err := ctx.GetStub().GetState(carID)
 
 	if err != nil {
@@ -43,50 +75,121 @@ func (c *CarContract) CarExists(ctx contractapi.TransactionContextInterface, car
 	return data != nil, nil
 }
 
-// CreateCar creates a new instance of Car
+// CreateCar creates a new instance of Car. The VIN is optional and given in
+// the transient field "vin"; it must pass the ISO 3779 check digit, and its
+// manufacturer code and model year are decoded into the car. The car ID is
+// taken as it is, whatever its length. The date of manufacture is stored as
+// YYYY-MM-DD. With the transient field "autoMatch" set to true the car is
+// matched to the oldest pending order for it in the same transaction, see
+// AutoMatch; when no order matches it stays in the factory.
 func (c *CarContract) CreateCar(ctx contractapi.TransactionContextInterface, carID string, make string, model string, color string, manufacturerName string, dateOfManufacture string) (string, error) {
-	clientOrgID, err := ctx.GetClientIdentity().GetMSPID()
+	if err := requireRole(ctx, RoleManufacturer); err != nil {
+		return "", err
+	}
+
+	autoMatch, err := autoMatchRequested(ctx)
+	if err != nil {
+		return "", err
+	}
+	vin, err := vinFromTransient(ctx)
+	if err != nil {
+		return "", err
+	}
+	car, err := createCar(ctx, &CarInput{
+		CarId:             carID,
+		Vin:               vin,
+		Make:              make,
+		Model:             model,
+		Color:             color,
+		ManufacturerName:  manufacturerName,
+		DateOfManufacture: dateOfManufacture,
+	}, autoMatch)
 	if err != nil {
 		return "", err
 	}
 
-	if clientOrgID == "Org1MSP" {
+	err = emitCarEvent(ctx, EventCarCreated, car)
+	if err != nil {
+		return "", err
+	} else if car.OrderId != "" {
+		return fmt.Sprintf("successfully added car %v and matched order %v", carID, car.OrderId), nil
+	} else {
+		return fmt.Sprintf("successfully added car %v", carID), nil
+	}
+}
 
-		exists, err := c.CarExists(ctx, carID)
-		if err != nil {
-			return "", fmt.Errorf("%s", err)
-		} else if exists {
-			return "", fmt.Errorf("the car, %s already exists", carID)
-		}
+// createCar validates the input and writes the new car to the world state.
+// With autoMatch the car is first matched to the oldest pending order for it,
+// if there is one, as the car must only be written once per transaction.
+func createCar(ctx contractapi.TransactionContextInterface, input *CarInput, autoMatch bool) (*Car, error) {
+	if input.CarId == "" {
+		return nil, fmt.Errorf("a car ID is required")
+	}
 
-		car := Car{
-			AssetType:         "car",
-			CarId:             carID,
-			Color:             color,
-			DateOfManufacture: dateOfManufacture,
-			Make:              make,
-			Model:             model,
-			OwnedBy:           manufacturerName,
-			Status:            "In Factory",
-		}
+	exists, err := carExists(ctx, input.CarId)
+	if err != nil {
+		return nil, fmt.Errorf("%s", err)
+	} else if exists {
+		return nil, fmt.Errorf("the car, %s already exists", input.CarId)
+	}
 
-		bytes, _ := json.Marshal(car)
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the transaction timestamp: %v", err)
+	}
+	manufactured, err := parseManufactureDate(input.DateOfManufacture, txTimestamp.AsTime())
+	if err != nil {
+		return nil, err
+	}
 
-		err = ctx.GetStub().PutState(carID, bytes)
-		if err != nil {
-			return "", err
-		} else {
-			return fmt.Sprintf("successfully added car %v", carID), nil
+	car := Car{
+		AssetType:         "car",
+		CarId:             input.CarId,
+		Color:             input.Color,
+		DateOfManufacture: manufactured.Format("2006-01-02"),
+		Make:              input.Make,
+		Model:             input.Model,
+		Status:            StatusInFactory,
+	}
+
+	if input.Vin != "" {
+		if err := validateVIN(input.Vin); err != nil {
+			return nil, err
 		}
+		car.Vin = input.Vin
+		car.ManufacturerCode, car.ModelYear = decodeVIN(input.Vin, manufactured)
+	}
 
-	} else {
-		return "", fmt.Errorf("user under following MSPID: %v can't perform this action", clientOrgID)
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the MSP ID of the client identity: %v", err)
+	}
+	err = changeOwner(ctx, &car, input.ManufacturerName, mspID)
+	if err != nil {
+		return nil, err
+	}
+	if autoMatch {
+		if _, err := autoMatchCar(ctx, &car); err != nil {
+			return nil, err
+		}
+	}
+
+	err = putCar(ctx, &car)
+	if err != nil {
+		return nil, err
 	}
+	return &car, nil
 }
 
 // ReadCar retrieves an instance of Car from the world state
 func (c *CarContract) ReadCar(ctx contractapi.TransactionContextInterface, carID string) (*Car, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+	return readCar(ctx, carID)
+}
 
+func readCar(ctx contractapi.TransactionContextInterface, carID string) (*Car, error) {
 	bytes, err := ctx.GetStub().GetState(carID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state: %v", err)
@@ -102,65 +205,139 @@ func (c *CarContract) ReadCar(ctx contractapi.TransactionContextInterface, carID
 	if err != nil {
 		return nil, fmt.Errorf("could not unmarshal world state data to type Car")
 	}
+	normalizeLegacyStatus(&car)
+	err = loadAnnotations(ctx, &car)
+	if err != nil {
+		return nil, err
+	}
 
 	return &car, nil
 }
 
 //Update car-contract with deletecar function
 
-// DeleteCar removes the instance of Car from the world state
+// DeleteCar removes the instance of Car from the world state. This hard
+// delete is reserved to the admin role; cars taken off the road are
+// decommissioned with ScrapCar instead. A car under an active lien cannot be
+// deleted.
 func (c *CarContract) DeleteCar(ctx contractapi.TransactionContextInterface, carID string) (string, error) {
+	if err := requireRole(ctx, RoleAdmin); err != nil {
+		return "", err
+	}
 
-	clientOrgID, err := ctx.GetClientIdentity().GetMSPID()
+	exists, err := carExists(ctx, carID)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("Could not read from world state. %s", err)
+	} else if !exists {
+		return "", fmt.Errorf("The asset %s does not exist", carID)
 	}
-	if clientOrgID == "Org1MSP" {
 
-		exists, err := c.CarExists(ctx, carID)
-		if err != nil {
-			return "", fmt.Errorf("Could not read from world state. %s", err)
-		} else if !exists {
-			return "", fmt.Errorf("The asset %s does not exist", carID)
-		}
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return "", err
+	}
+	if err := checkLien(car); err != nil {
+		return "", err
+	}
 
-		err = ctx.GetStub().DelState(carID)
-		if err != nil {
-			return "", err
-		} else {
-			return fmt.Sprintf("Car with id %v is deleted from the world state.", carID), nil
-		}
+	err = deleteCar(ctx, car)
+	if err != nil {
+		return "", err
+	}
 
+	err = emitCarEvent(ctx, EventCarDeleted, car)
+	if err != nil {
+		return "", err
 	} else {
-		return "", fmt.Errorf("User under following MSP:%v cannot able to perform this action", clientOrgID)
+		return fmt.Sprintf("Car with id %v is deleted from the world state.", carID), nil
 	}
 }
 
-// GetAllCars retrieves all the asset with assetype 'car'
+// GetAllCars retrieves all the asset with assetype 'car', except the scrapped ones
 
 func (c *CarContract) GetAllCars(ctx contractapi.TransactionContextInterface) ([]*Car, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
 
-	// queryString := `{"selector":{"assetType":"car"}}`
+	// Every car has a status index key, so the cars are found through the
+	// index of each status they can be listed in, without scanning the whole
+	// world state.
+	cars := []*Car{}
+	for _, status := range []CarStatus{StatusInFactory, StatusAssignedToDealer, StatusRegistered} {
+		results, err := getCarsByIndex(ctx, carStatusIndex, []string{string(status)})
+		if err != nil {
+			return nil, err
+		}
+		for _, car := range results {
+			if car.AssetType == "car" {
+				cars = append(cars, car)
+			}
+		}
+	}
 
-	queryString := `{"selector":{"assetType":"car"}, "sort":[{ "carId": "desc"}]}`
+	// newest car IDs first
+	sort.Slice(cars, func(i, j int) bool {
+		return cars[i].CarId > cars[j].CarId
+	})
 
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	return cars, nil
 
-	if err != nil {
+}
 
+// GetCarsByOwner retrieves all the cars currently owned by the given owner,
+// except the scrapped ones
+func (c *CarContract) GetCarsByOwner(ctx contractapi.TransactionContextInterface, owner string) ([]*Car, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
 		return nil, err
+	}
+	cars, err := getCarsByIndex(ctx, carOwnerIndex, []string{owner})
+	if err != nil {
+		return nil, err
+	}
+	return withoutScrapped(cars), nil
+}
 
+// GetCarsByAttributes retrieves the cars of the given make, model and color.
+// Trailing attributes may be left empty to widen the search, e.g. make only or
+// make and model. Scrapped cars are left out.
+func (c *CarContract) GetCarsByAttributes(ctx contractapi.TransactionContextInterface, make string, model string, color string) ([]*Car, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
 	}
 
-	defer resultsIterator.Close()
+	attributes := []string{make, model, color}
+	for len(attributes) > 0 && attributes[len(attributes)-1] == "" {
+		attributes = attributes[:len(attributes)-1]
+	}
+	for _, attribute := range attributes {
+		if attribute == "" {
+			return nil, fmt.Errorf("attributes must be given in the order make, model, color without gaps")
+		}
+	}
 
-	return carResultIteratorFunction(resultsIterator)
+	cars, err := getCarsByIndex(ctx, carAttributesIndex, attributes)
+	if err != nil {
+		return nil, err
+	}
+	return withoutScrapped(cars), nil
+}
 
+// GetCarsByStatus retrieves all the cars in the given lifecycle status. This
+// is how scrapped cars are listed.
+func (c *CarContract) GetCarsByStatus(ctx contractapi.TransactionContextInterface, status string) ([]*Car, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+	if _, ok := carStatusTransitions[CarStatus(status)]; !ok {
+		return nil, fmt.Errorf("unknown car status %s", status)
+	}
+	return getCarsByIndex(ctx, carStatusIndex, []string{status})
 }
 
 // Iterator function
 
-func carResultIteratorFunction(resultsIterator shim.StateQueryIteratorInterface) ([]*Car, error) {
+func carResultIteratorFunction(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface) ([]*Car, error) {
 
 	var cars []*Car
 
@@ -183,6 +360,11 @@ func carResultIteratorFunction(resultsIterator shim.StateQueryIteratorInterface)
 			return nil, err
 
 		}
+		normalizeLegacyStatus(&car)
+		err = loadAnnotations(ctx, &car)
+		if err != nil {
+			return nil, err
+		}
 
 		cars = append(cars, &car)
 
@@ -192,126 +374,224 @@ func carResultIteratorFunction(resultsIterator shim.StateQueryIteratorInterface)
 
 }
 
+// GetCarsByRange retrieves the cars with keys in [startKey, endKey), except
+// the scrapped ones
 func (c *CarContract) GetCarsByRange(ctx contractapi.TransactionContextInterface, startKey, endKey string) ([]*Car, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
 	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
 	if err != nil {
 		return nil, err
 	}
 	defer resultsIterator.Close()
-	return carResultIteratorFunction(resultsIterator)
+	cars, err := carResultIteratorFunction(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+	return withoutScrapped(cars), nil
 }
 
-// GetCarHistory returns the history of a car since issuance.
-func (c *CarContract) GetCarHistory(ctx contractapi.TransactionContextInterface, carID string) ([]*HistoryQueryResult, error) {
-	resultsIterator, err := ctx.GetStub().GetHistoryForKey(carID)
+// GetAllCarsWithPagination retrieves one page of the assets with assetType
+// 'car' that are not scrapped, newest car IDs first. Pass the returned
+// bookmark to fetch the next page.
+func (c *CarContract) GetAllCarsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedCarResult, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	queryString, err := couchquery.New().
+		Eq("assetType", "car").
+		Where("status", couchquery.Ne, string(StatusScrapped)).
+		Sort("carId", true).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
 	if err != nil {
 		return nil, err
 	}
 	defer resultsIterator.Close()
-	var records []*HistoryQueryResult
-	for resultsIterator.HasNext() {
-		response, err := resultsIterator.Next()
 
-		if err != nil {
-			return nil, err
-		}
-		var car Car
-		if len(response.Value) > 0 {
-			err = json.Unmarshal(response.Value, &car)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			car = Car{CarId: carID}
-		}
-		timestamp := response.Timestamp.AsTime()
-		formattedTime := timestamp.Format(time.RFC1123)
-		record := HistoryQueryResult{
-			TxId:      response.TxId,
-			Timestamp: formattedTime,
-			Record:    &car,
-			IsDelete:  response.IsDelete,
-		}
-		records = append(records, &record)
+	return carPageFunction(ctx, resultsIterator, responseMetadata)
+}
+
+// GetCarsByRangeWithPagination retrieves one page of the cars with keys in
+// [startKey, endKey). Pass the returned bookmark to fetch the next page.
+// Scrapped cars are left out of the records, so a page may hold fewer records
+// than fetchedRecordsCount.
+func (c *CarContract) GetCarsByRangeWithPagination(ctx contractapi.TransactionContextInterface, startKey string, endKey string, pageSize int32, bookmark string) (*PaginatedCarResult, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
 	}
-	return records, nil
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByRangeWithPagination(startKey, endKey, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return carPageFunction(ctx, resultsIterator, responseMetadata)
+}
+
+// carPageFunction wraps the cars of a paginated query that are not scrapped
+// in a PaginatedCarResult
+func carPageFunction(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface, responseMetadata *peer.QueryResponseMetadata) (*PaginatedCarResult, error) {
+	cars, err := carResultIteratorFunction(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedCarResult{
+		Records:             withoutScrapped(cars),
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
 }
 
+// GetMatchingOrders returns the pending orders the car can be matched to, the
+// oldest first and, among orders placed at the same time, the one with the
+// most cars still to allocate first
 func (c *CarContract) GetMatchingOrders(ctx contractapi.TransactionContextInterface, carID string) ([]*Order, error) {
-	exists, err := c.CarExists(ctx, carID)
+	if err := requireRole(ctx, RoleManufacturer); err != nil {
+		return nil, err
+	}
+
+	exists, err := carExists(ctx, carID)
 	if err != nil {
 		return nil, fmt.Errorf("Could not read from world state. %s", err)
 	} else if !exists {
 		return nil, fmt.Errorf("The asset %s does not exist", carID)
 	}
-	car, err := c.ReadCar(ctx, carID)
+	car, err := readCar(ctx, carID)
 	if err != nil {
 		return nil, fmt.Errorf("Error reading car %v", err)
 	}
-	queryString := fmt.Sprintf(`{"selector":{"assetType":"Order","make":"%s", "model": "%s", "color":"%s"}}`, car.Make, car.Model, car.Color)
-	resultsIterator, err := ctx.GetStub().GetPrivateDataQueryResult(getCollectionName(), queryString)
+	orders, err := getOrdersByAttributes(ctx, car.Make, car.Model, car.Color)
 	if err != nil {
 		return nil, err
 	}
-	defer resultsIterator.Close()
-	return orderResultIteratorFunction(resultsIterator)
+	rankOrders(orders)
+	return orders, nil
 }
 
-// MatchOrder matches car with matching order
+// MatchOrder matches car with matching order. The car is added to the
+// allocations of the order, which becomes Matched once it has all the cars it
+// asked for and is kept in the collection. The car references the order it
+// fulfilled and records the match decision.
 func (c *CarContract) MatchOrder(ctx contractapi.TransactionContextInterface, carID string, orderID string) (string, error) {
+	if err := requireRole(ctx, RoleManufacturer); err != nil {
+		return "", err
+	}
+
 	order, err := ReadPrivateState(ctx, orderID)
 	if err != nil {
 		return "", err
 	}
-	car, err := c.ReadCar(ctx, carID)
+	car, err := readCar(ctx, carID)
 	if err != nil {
 		return "", err
 	}
-	if car.Make == order.Make && car.Color == order.Color && car.Model == order.Model {
-		car.OwnedBy = order.DealerName
-		car.Status = "assigned to a dealer"
-
-		bytes, _ := json.Marshal(car)
-		
-		collectionName := getCollectionName()
-		ctx.GetStub().DelPrivateData(collectionName, orderID)
-		err = ctx.GetStub().PutState(carID, bytes)
-		if err != nil {
-			return "", err
-		} else {
-			return fmt.Sprintf("Deleted order %v and Assigned %v to %v", orderID, car.CarId, order.DealerName), nil
-		}
+	err = matchCarToOrder(ctx, car, order, nil)
+	if err != nil {
+		return "", err
+	}
+	err = putCar(ctx, car)
+	if err != nil {
+		return "", err
+	}
+	err = emitOrderEvent(ctx, EventOrderMatched, orderID, carID)
+	if err != nil {
+		return "", err
 	} else {
-		return "", fmt.Errorf("order is not matching")
+		return fmt.Sprintf("Matched order %v and Assigned %v to %v", orderID, car.CarId, order.DealerName), nil
 	}
 }
 
-// RegisterCar register car to the buyer
-func (c *CarContract) RegisterCar(ctx contractapi.TransactionContextInterface, carID string, ownerName string, registrationNumber string) (string, error) {
-	clientOrgID, err := ctx.GetClientIdentity().GetMSPID()
-	if err != nil {
+// RegisterCar register car to the buyer, the identity with the enrollment ID
+// ownerName in the org ownerMspID, under a registration number that no other
+// car holds and that was never retired. The dealer must have proposed the sale
+// to the buyer, the buyer accepted it and both agreed to the same sale price
+// with AgreeSalePrice; their agreements are removed once the car is registered.
+func (c *CarContract) RegisterCar(ctx contractapi.TransactionContextInterface, carID string, ownerName string, ownerMspID string, registrationNumber string) (string, error) {
+	if err := requireRole(ctx, RoleRTO); err != nil {
 		return "", err
 	}
-	if clientOrgID == "Org3MSP" {
-		exists, err := c.CarExists(ctx, carID)
+	ownerMspID = strings.TrimSpace(ownerMspID)
+	if ownerName == "" || ownerMspID == "" {
+		return "", fmt.Errorf("the owner and the MSP ID of the owner's org are required to register car %s", carID)
+	}
+
+	exists, err := carExists(ctx, carID)
+	if err != nil {
+		return "", fmt.Errorf("Could not read from world state. %s", err)
+	}
+	if exists {
+		car, err := readCar(ctx, carID)
 		if err != nil {
-			return "", fmt.Errorf("Could not read from world state. %s", err)
+			return "", err
 		}
-		if exists {
-			car, _ := c.ReadCar(ctx, carID)
-			car.Status = fmt.Sprintf("Registered to %v with plate number %v", ownerName, registrationNumber)
-			car.OwnedBy = ownerName
-			bytes, _ := json.Marshal(car)
-			err = ctx.GetStub().PutState(carID, bytes)
-			if err != nil {
-				return "", err
-			} else {
-				return fmt.Sprintf("Car %v successfully registered to %v", carID, ownerName), nil
-			}
+		if err := transitionCar(car, StatusRegistered); err != nil {
+			return "", err
+		}
+		transfer := car.PendingTransfer
+		if transfer == nil || transfer.Status != TransferAccepted || transfer.Buyer != ownerName || transfer.BuyerMspId != ownerMspID {
+			return "", fmt.Errorf("car %s has no accepted sale to %s of %s awaiting registration", carID, ownerName, ownerMspID)
+		}
+		if err := checkBlockingRecalls(car); err != nil {
+			return "", err
+		}
+		if err := verifySaleAgreement(ctx, car); err != nil {
+			return "", err
+		}
+		plate, err := checkPlate(ctx, registrationNumber, carID)
+		if err != nil {
+			return "", err
+		}
+		registrationDate, err := getTxTimestamp(ctx)
+		if err != nil {
+			return "", err
+		}
+		err = deleteSaleAgreements(ctx, car)
+		if err != nil {
+			return "", err
+		}
+		err = changeOwner(ctx, car, ownerName, ownerMspID)
+		if err != nil {
+			return "", err
+		}
+		car.PendingTransfer = nil
+		car.RegistrationNumber = plate
+		car.RegistrationDate = registrationDate
+		err = putCar(ctx, car)
+		if err != nil {
+			return "", err
+		}
+		err = emitCarEvent(ctx, EventCarRegistered, car)
+		if err != nil {
+			return "", err
 		} else {
-			return "", fmt.Errorf("Car %v does not exist!", carID)
+			return fmt.Sprintf("Car %v successfully registered to %v", carID, ownerName), nil
 		}
 	} else {
-		return "", fmt.Errorf("User under following MSPID: %v cannot able to perform this action", clientOrgID)
+		return "", fmt.Errorf("Car %v does not exist!", carID)
+	}
+}
+
+// getTxTimestamp returns the transaction timestamp formatted as RFC 3339. The
+// timestamp is chosen by the client, so it is the same on every endorsing peer.
+func getTxTimestamp(ctx contractapi.TransactionContextInterface) (string, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the transaction timestamp: %v", err)
 	}
+	return timestamp.AsTime().UTC().Format(time.RFC3339), nil
 }
@@ -0,0 +1,100 @@
+package contracts
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/msp"
+	"google.golang.org/protobuf/proto"
+)
+
+// setOwnerEndorsement sets the key-level endorsement policy of a car so that
+// any further change to it must be endorsed by a peer of the owning org. An
+// empty MSP ID removes the key-level policy, leaving the chaincode policy in
+// charge. The liens of the car, once it has any, follow the car.
+func setOwnerEndorsement(ctx contractapi.TransactionContextInterface, carID string, mspID string) error {
+	policy, err := keyEndorsementPolicy(mspID)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().SetStateValidationParameter(carID, policy)
+	if err != nil {
+		return fmt.Errorf("failed to set the endorsement policy of car %s: %v", carID, err)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(carAnnotationIndex, []string{carID, annotationLiens})
+	if err != nil {
+		return err
+	}
+	liens, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if liens == nil {
+		return nil
+	}
+	return setLienEndorsement(ctx, carID, mspID)
+}
+
+// setLienEndorsement gives the lien key of a car the key-level endorsement
+// policy of the car. An active lien stops the car from changing hands, so it
+// must not be lifted without a peer of the owning org. The other annotations
+// stay under the chaincode endorsement policy: the recalls and odometer
+// readings of many cars are written by the manufacturer and the service
+// centers, which could not gather a peer of every owning org.
+func setLienEndorsement(ctx contractapi.TransactionContextInterface, carID string, mspID string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(carAnnotationIndex, []string{carID, annotationLiens})
+	if err != nil {
+		return err
+	}
+	policy, err := keyEndorsementPolicy(mspID)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().SetStateValidationParameter(key, policy)
+	if err != nil {
+		return fmt.Errorf("failed to set the endorsement policy of the liens of car %s: %v", carID, err)
+	}
+	return nil
+}
+
+// keyEndorsementPolicy returns the key-level policy requiring a peer of the
+// org, or nil, which removes the key-level policy, for an empty MSP ID
+func keyEndorsementPolicy(mspID string) ([]byte, error) {
+	if mspID == "" {
+		return nil, nil
+	}
+	return ownerEndorsementPolicy(mspID)
+}
+
+// ownerEndorsementPolicy returns the serialized signature policy requiring
+// the signature of one peer of the given org, i.e. OR('<mspID>.peer')
+func ownerEndorsementPolicy(mspID string) ([]byte, error) {
+	role, err := proto.Marshal(&msp.MSPRole{MspIdentifier: mspID, Role: msp.MSPRole_PEER})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the peer role of %s: %v", mspID, err)
+	}
+
+	envelope := &common.SignaturePolicyEnvelope{
+		Version: 0,
+		Rule: &common.SignaturePolicy{
+			Type: &common.SignaturePolicy_NOutOf_{
+				NOutOf: &common.SignaturePolicy_NOutOf{
+					N: 1,
+					Rules: []*common.SignaturePolicy{
+						{Type: &common.SignaturePolicy_SignedBy{SignedBy: 0}},
+					},
+				},
+			},
+		},
+		Identities: []*msp.MSPPrincipal{
+			{PrincipalClassification: msp.MSPPrincipal_ROLE, Principal: role},
+		},
+	}
+	policy, err := proto.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the endorsement policy of %s: %v", mspID, err)
+	}
+	return policy, nil
+}
@@ -0,0 +1,339 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// HistoryQueryResult is one version of a car from the history of its key and
+// of its annotation keys
+type HistoryQueryResult struct {
+	Record      *Car           `json:"record"`
+	TxId        string         `json:"txId"`
+	Timestamp   string         `json:"timestamp"`
+	IsDelete    bool           `json:"isDelete"`
+	SubmittedBy *Submitter     `json:"submittedBy,omitempty" metadata:",optional"`
+	Changes     []*FieldChange `json:"changes"`
+}
+
+// PaginatedHistoryResult is one page of the history of a car together with
+// the bookmark to pass in to fetch the next page
+type PaginatedHistoryResult struct {
+	Records             []*HistoryQueryResult `json:"records"`
+	FetchedRecordsCount int32                 `json:"fetchedRecordsCount"`
+	Bookmark            string                `json:"bookmark"`
+}
+
+// GetCarHistory returns the history of a car since issuance, oldest version
+// first. Timestamps are RFC 3339 and every version lists the fields that
+// changed since the version before it.
+func (c *CarContract) GetCarHistory(ctx contractapi.TransactionContextInterface, carID string) ([]*HistoryQueryResult, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+	return getCarHistory(ctx, carID)
+}
+
+// CarAsOfResult is the state of a car at a point in time
+type CarAsOfResult struct {
+	CarId     string `json:"carId"`
+	AsOf      string `json:"asOf"`
+	Exists    bool   `json:"exists"`
+	Record    *Car   `json:"record,omitempty" metadata:",optional"`
+	TxId      string `json:"txId,omitempty" metadata:",optional"`
+	Timestamp string `json:"timestamp,omitempty" metadata:",optional"`
+}
+
+// GetCarAsOf returns the version of a car that was current at the given RFC
+// 3339 timestamp, together with the transaction that wrote it. exists is false
+// when the car had not been created yet or had been deleted at that time.
+func (c *CarContract) GetCarAsOf(ctx contractapi.TransactionContextInterface, carID string, timestamp string) (*CarAsOfResult, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+	asOf, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("timestamp must be an RFC 3339 timestamp such as 2024-01-31T00:00:00Z, got %s", timestamp)
+	}
+
+	history, err := getCarHistory(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CarAsOfResult{CarId: carID, AsOf: asOf.UTC().Format(time.RFC3339Nano)}
+	for _, record := range history {
+		written, _ := time.Parse(time.RFC3339Nano, record.Timestamp)
+		if written.After(asOf) {
+			break
+		}
+		result.Exists = !record.IsDelete
+		result.TxId = record.TxId
+		result.Timestamp = record.Timestamp
+		result.Record = record.Record
+	}
+	if !result.Exists {
+		result.Record = nil
+	}
+	return result, nil
+}
+
+// GetCarHistoryWithPagination returns one page of the versions of a car
+// written in the time window [from, to), oldest first. from and to are RFC 3339
+// timestamps and either may be left empty for an open window. Pass the
+// returned bookmark to fetch the next page.
+func (c *CarContract) GetCarHistoryWithPagination(ctx contractapi.TransactionContextInterface, carID string, from string, to string, pageSize int32, bookmark string) (*PaginatedHistoryResult, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+	fromTime, err := parseTimeBound("from", from)
+	if err != nil {
+		return nil, err
+	}
+	toTime, err := parseTimeBound("to", to)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := getCarHistory(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &PaginatedHistoryResult{Records: []*HistoryQueryResult{}}
+	started := bookmark == ""
+	for _, record := range history {
+		if !started {
+			started = record.TxId == bookmark
+			if !started {
+				continue
+			}
+		}
+		timestamp, _ := time.Parse(time.RFC3339Nano, record.Timestamp)
+		if !fromTime.IsZero() && timestamp.Before(fromTime) {
+			continue
+		}
+		if !toTime.IsZero() && !timestamp.Before(toTime) {
+			continue
+		}
+		if int32(len(page.Records)) == pageSize {
+			page.Bookmark = record.TxId
+			break
+		}
+		page.Records = append(page.Records, record)
+	}
+	if !started {
+		return nil, fmt.Errorf("bookmark %s is not part of the history of car %s", bookmark, carID)
+	}
+	page.FetchedRecordsCount = int32(len(page.Records))
+	return page, nil
+}
+
+// keyVersion is one version of a key from its history
+type keyVersion struct {
+	kind      string
+	txID      string
+	timestamp time.Time
+	isDelete  bool
+	value     []byte
+}
+
+// getCarHistory reads the key history of a car and of its annotations, oldest
+// version first, and works out the changes each version made to the one before
+// it. A transaction writing the car and its annotations gives one version.
+func getCarHistory(ctx contractapi.TransactionContextInterface, carID string) ([]*HistoryQueryResult, error) {
+	versions, err := getKeyHistory(ctx, "", carID)
+	if err != nil {
+		return nil, err
+	}
+	for _, kind := range annotationKinds {
+		key, err := ctx.GetStub().CreateCompositeKey(carAnnotationIndex, []string{carID, kind})
+		if err != nil {
+			return nil, err
+		}
+		annotations, err := getKeyHistory(ctx, kind, key)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, annotations...)
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].timestamp.Before(versions[j].timestamp)
+	})
+
+	records := []*HistoryQueryResult{}
+	byTx := map[string]*HistoryQueryResult{}
+	var car *Car
+	annotations := map[string][]byte{}
+	for _, v := range versions {
+		if v.kind != "" {
+			annotations[v.kind] = v.value
+			if v.isDelete {
+				delete(annotations, v.kind)
+			}
+		} else if v.isDelete {
+			car = nil
+		} else {
+			car = new(Car)
+			if err := json.Unmarshal(v.value, car); err != nil {
+				return nil, err
+			}
+			normalizeLegacyStatus(car)
+		}
+
+		record, ok := byTx[v.txID]
+		if !ok {
+			if car == nil && v.kind != "" {
+				// the annotations of a deleted car go with it
+				continue
+			}
+			record = &HistoryQueryResult{TxId: v.txID, Timestamp: v.timestamp.Format(time.RFC3339Nano)}
+			byTx[v.txID] = record
+			records = append(records, record)
+		}
+		if v.kind == "" {
+			// annotations carry no submitter stamp, the car does
+			record.SubmittedBy = nil
+			if car != nil {
+				record.SubmittedBy = car.ModifiedBy
+			}
+		}
+		record.IsDelete = car == nil
+		record.Record = &Car{CarId: carID}
+		if car != nil {
+			merged := *car
+			for _, kind := range annotationKinds {
+				if value, ok := annotations[kind]; ok {
+					if err := applyAnnotation(&merged, kind, value); err != nil {
+						return nil, err
+					}
+				}
+			}
+			record.Record = &merged
+		}
+	}
+
+	previous := &Car{}
+	for _, record := range records {
+		changes, err := diffCars(previous, record.Record)
+		if err != nil {
+			return nil, err
+		}
+		record.Changes = changes
+		previous = record.Record
+	}
+	return records, nil
+}
+
+// getKeyHistory reads the history of a key, oldest version first. kind is the
+// annotation kind of the key, or empty for the car key.
+func getKeyHistory(ctx contractapi.TransactionContextInterface, kind string, key string) ([]*keyVersion, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var versions []*keyVersion
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, &keyVersion{
+			kind:      kind,
+			txID:      response.TxId,
+			timestamp: response.Timestamp.AsTime().UTC(),
+			isDelete:  response.IsDelete,
+			value:     response.Value,
+		})
+	}
+
+	// depending on the peer, the history comes newest or oldest first; bring it
+	// into chronological order, keeping versions with equal timestamps in
+	// ledger order
+	if len(versions) > 1 && versions[0].timestamp.After(versions[len(versions)-1].timestamp) {
+		for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
+			versions[i], versions[j] = versions[j], versions[i]
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].timestamp.Before(versions[j].timestamp)
+	})
+	return versions, nil
+}
+
+// diffCars lists the fields that differ between two versions of a car. Values
+// that are not strings are given as JSON. The modifiedBy stamp is left out; it
+// is reported as the submitter of the version instead.
+func diffCars(previous *Car, current *Car) ([]*FieldChange, error) {
+	before, err := carFields(previous)
+	if err != nil {
+		return nil, err
+	}
+	after, err := carFields(current)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(after))
+	for field := range before {
+		fields = append(fields, field)
+	}
+	for field := range after {
+		if _, ok := before[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []*FieldChange{}
+	for _, field := range fields {
+		if field == "modifiedBy" || before[field] == after[field] {
+			continue
+		}
+		changes = append(changes, &FieldChange{Field: field, From: before[field], To: after[field]})
+	}
+	return changes, nil
+}
+
+// carFields returns the top level JSON fields of a car as text
+func carFields(car *Car) (map[string]string, error) {
+	bytes, err := json.Marshal(car)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(bytes, &raw); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string, len(raw))
+	for field, value := range raw {
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil {
+			text = string(value)
+		}
+		fields[field] = text
+	}
+	return fields, nil
+}
+
+// parseTimeBound parses an optional RFC 3339 bound of a time window
+func parseTimeBound(name string, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	bound, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp such as 2024-01-31T00:00:00Z, got %s", name, value)
+	}
+	return bound, nil
+}
@@ -0,0 +1,112 @@
+package contracts
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffCars(t *testing.T) {
+	base := func() *Car {
+		return &Car{AssetType: "car", CarId: "C1", Make: "Maruti", Model: "Alto", Color: "Red", OwnedBy: "fac", Status: StatusInFactory}
+	}
+	tests := []struct {
+		name    string
+		change  func(car *Car)
+		changes []*FieldChange
+	}{
+		{
+			name:    "no change",
+			change:  func(car *Car) {},
+			changes: []*FieldChange{},
+		},
+		{
+			name:    "string field",
+			change:  func(car *Car) { car.Color = "Blue" },
+			changes: []*FieldChange{{Field: "color", From: "Red", To: "Blue"}},
+		},
+		{
+			name: "several fields in field order",
+			change: func(car *Car) {
+				car.Status = StatusAssignedToDealer
+				car.OwnedBy = "Popular"
+			},
+			changes: []*FieldChange{
+				{Field: "ownedBy", From: "fac", To: "Popular"},
+				{Field: "status", From: "InFactory", To: "AssignedToDealer"},
+			},
+		},
+		{
+			name:    "added field",
+			change:  func(car *Car) { car.RegistrationNumber = "KA01" },
+			changes: []*FieldChange{{Field: "registrationNumber", From: "", To: "KA01"}},
+		},
+		{
+			name:    "non-string field as JSON",
+			change:  func(car *Car) { car.ModelYear = 2019 },
+			changes: []*FieldChange{{Field: "modelYear", From: "", To: "2019"}},
+		},
+		{
+			name:    "modifiedBy is left out",
+			change:  func(car *Car) { car.ModifiedBy = &Submitter{MspId: "Org1MSP", Name: "factory1"} },
+			changes: []*FieldChange{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous, current := base(), base()
+			tt.change(current)
+			changes, err := diffCars(previous, current)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(changes, tt.changes) {
+				t.Fatalf("diffCars = %v, want %v", fieldChanges(changes), fieldChanges(tt.changes))
+			}
+		})
+	}
+}
+
+// fieldChanges dereferences changes for readable failure messages
+func fieldChanges(changes []*FieldChange) []FieldChange {
+	values := make([]FieldChange, len(changes))
+	for i, change := range changes {
+		values[i] = *change
+	}
+	return values
+}
+
+func TestCarHistoryAnnotations(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke(testAdmin, nil, "AccessContract:SetRoleMSPs", RoleLender, `["Org5MSP"]`)
+	addDealer(l, testDealer)
+	deliverCar(l, testDealer, "C1")
+	l.mustInvoke(testLender, nil, "CarContract:PlaceLien", "C1", "loan-1")
+	placed := l.now
+	l.mustInvoke(testDealer, nil, "CarContract:ApproveLien", "C1")
+
+	var history []*HistoryQueryResult
+	if err := json.Unmarshal([]byte(l.mustInvoke(testDealer, nil, "CarContract:GetCarHistory", "C1")), &history); err != nil {
+		t.Fatal(err)
+	}
+	last := history[len(history)-1]
+	if last.Record.Lien == nil || last.Record.PendingLien != nil || last.SubmittedBy != nil {
+		t.Fatalf("last version = %+v, want the approved lien without a submitter", last)
+	}
+	fields := map[string]bool{}
+	for _, change := range last.Changes {
+		fields[change.Field] = true
+	}
+	if !fields["lien"] || !fields["pendingLien"] || len(fields) != 2 {
+		t.Fatalf("last changes = %v, want lien and pendingLien", fieldChanges(last.Changes))
+	}
+
+	var asOf CarAsOfResult
+	if err := json.Unmarshal([]byte(l.mustInvoke(testDealer, nil, "CarContract:GetCarAsOf", "C1", placed.Format(time.RFC3339))), &asOf); err != nil {
+		t.Fatal(err)
+	}
+	if !asOf.Exists || asOf.Record.PendingLien == nil || asOf.Record.Lien != nil {
+		t.Fatalf("car as of %s = %+v, want the pending lien", placed, asOf.Record)
+	}
+}
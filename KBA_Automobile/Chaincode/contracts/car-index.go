@@ -0,0 +1,168 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Composite-key indexes kept next to every car so that cars can be looked up
+// with GetStateByPartialCompositeKey, which works on both LevelDB and CouchDB.
+// The car ID is always the last attribute of an index key.
+const (
+	carAttributesIndex = "make~model~color"
+	carOwnerIndex      = "owner~carId"
+	carStatusIndex     = "status~carId"
+	carPlateIndex      = "plate~carId"
+)
+
+// indexValue is stored under every index key; only the key itself matters
+var indexValue = []byte{0x00}
+
+// compositeIndex is one composite index entry of a car or an order
+type compositeIndex struct {
+	objectType string
+	attributes []string
+}
+
+// carIndexKeys returns the composite index keys of the given car
+func carIndexKeys(ctx contractapi.TransactionContextInterface, car *Car) ([]string, error) {
+	stub := ctx.GetStub()
+	indexes := []compositeIndex{
+		{carAttributesIndex, []string{car.Make, car.Model, car.Color, car.CarId}},
+		{carOwnerIndex, []string{car.OwnedBy, car.CarId}},
+		{carStatusIndex, []string{string(car.Status), car.CarId}},
+	}
+	if car.RegistrationNumber != "" {
+		indexes = append(indexes, compositeIndex{carPlateIndex, []string{normalizePlate(car.RegistrationNumber), car.CarId}})
+	}
+
+	var keys []string
+	for _, index := range indexes {
+		key, err := stub.CreateCompositeKey(index.objectType, index.attributes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s index key for car %s: %v", index.objectType, car.CarId, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// putCar writes the car to the world state and brings its index keys in line
+// with the new values. Index keys of the version currently on the ledger that
+// no longer apply are removed. The submitting identity is stamped on the car,
+// so the key history shows who wrote each version. The annotations of the car
+// are not written with it, see putCarAnnotation, except to move them out of a
+// car that still holds them from an earlier version of this chaincode.
+func putCar(ctx contractapi.TransactionContextInterface, car *Car) error {
+	stub := ctx.GetStub()
+
+	submitter, err := getSubmitter(ctx)
+	if err != nil {
+		return err
+	}
+	car.ModifiedBy = submitter
+
+	newKeys, err := carIndexKeys(ctx, car)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(newKeys))
+	for _, key := range newKeys {
+		keep[key] = true
+	}
+
+	stored, err := stub.GetState(car.CarId)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if stored != nil {
+		var previous Car
+		if err := json.Unmarshal(stored, &previous); err != nil {
+			return fmt.Errorf("could not unmarshal world state data to type Car")
+		}
+		normalizeLegacyStatus(&previous)
+		if hasAnnotations(&previous) {
+			for _, kind := range annotationKinds {
+				if err := putCarAnnotation(ctx, car, kind); err != nil {
+					return err
+				}
+			}
+		}
+		oldKeys, err := carIndexKeys(ctx, &previous)
+		if err != nil {
+			return err
+		}
+		for _, key := range oldKeys {
+			if keep[key] {
+				continue
+			}
+			if err := stub.DelState(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	bytes, _ := json.Marshal(withoutAnnotations(car))
+	if err := stub.PutState(car.CarId, bytes); err != nil {
+		return err
+	}
+	for _, key := range newKeys {
+		if err := stub.PutState(key, indexValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteCar removes the car, its annotations and all of its index keys from
+// the world state. Its registration number is retired first, so that it is
+// never issued again.
+func deleteCar(ctx contractapi.TransactionContextInterface, car *Car) error {
+	if err := deleteCarAnnotations(ctx, car.CarId); err != nil {
+		return err
+	}
+	keys, err := carIndexKeys(ctx, car)
+	if err != nil {
+		return err
+	}
+	if err := retirePlate(ctx, car, "deleted"); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return err
+		}
+	}
+	return ctx.GetStub().DelState(car.CarId)
+}
+
+// getCarsByIndex returns the cars whose index keys start with the given
+// attributes
+func getCarsByIndex(ctx contractapi.TransactionContextInterface, objectType string, attributes []string) ([]*Car, error) {
+	stub := ctx.GetStub()
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var cars []*Car
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, keyParts, err := stub.SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, err
+		}
+		car, err := readCar(ctx, keyParts[len(keyParts)-1])
+		if err != nil {
+			return nil, err
+		}
+		cars = append(cars, car)
+	}
+	return cars, nil
+}
@@ -0,0 +1,105 @@
+package contracts
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// carIDs returns the IDs of the cars a listing transaction returns
+func carIDs(l *testLedger, caller testIdentity, args ...string) []string {
+	l.t.Helper()
+	var cars []*Car
+	if err := json.Unmarshal([]byte(l.mustInvoke(caller, nil, args...)), &cars); err != nil {
+		l.t.Fatal(err)
+	}
+	ids := []string{}
+	for _, car := range cars {
+		ids = append(ids, car.CarId)
+	}
+	return ids
+}
+
+// checkCarIDs fails the test unless the listing returns the given car IDs
+func checkCarIDs(l *testLedger, want []string, args ...string) {
+	l.t.Helper()
+	got := carIDs(l, testManufacturer, args...)
+	if len(got) != len(want) {
+		l.t.Fatalf("%v = %v, want %v", args, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			l.t.Fatalf("%v = %v, want %v", args, got, want)
+		}
+	}
+}
+
+func TestCarIndexes(t *testing.T) {
+	l := newTestLedger(t)
+	addDealer(l, testDealer)
+	l.mustInvoke(testManufacturer, nil, "CarContract:CreateCar", "C1", "Maruti", "Swift", "Blue", "fac", "2019-10-25")
+	deliverCar(l, testDealer, "C2")
+
+	if !l.hasKey(carAttributesIndex, "Maruti", "Swift", "Blue", "C1") || !l.hasKey(carOwnerIndex, "fac", "C1") || !l.hasKey(carStatusIndex, string(StatusInFactory), "C1") {
+		t.Fatal("the index keys of C1 are missing")
+	}
+	checkCarIDs(l, []string{"C2", "C1"}, "CarContract:GetAllCars")
+	checkCarIDs(l, []string{"C2"}, "CarContract:GetCarsByOwner", testDealer.name)
+	checkCarIDs(l, []string{"C1"}, "CarContract:GetCarsByAttributes", "Maruti", "Swift", "Blue")
+	checkCarIDs(l, []string{"C2"}, "CarContract:GetCarsByAttributes", "Maruti", "Alto", "")
+
+	sellCar(l, testDealer, testOwner("dani"), "C2", "KA01")
+	if l.hasKey(carStatusIndex, string(StatusAssignedToDealer), "C2") || !l.hasKey(carPlateIndex, "KA01", "C2") || !l.hasKey(carOwnerIndex, "dani", "C2") {
+		t.Fatal("the index keys of C2 do not follow its registration")
+	}
+	checkCarIDs(l, []string{"C2"}, "CarContract:GetCarsByStatus", string(StatusRegistered))
+
+	// scrapped cars keep their keys but are left out of GetAllCars
+	l.mustInvoke(testManufacturer, nil, "CarContract:ScrapCar", "C1", "flood damage", "cert-1")
+	checkCarIDs(l, []string{"C2"}, "CarContract:GetAllCars")
+	checkCarIDs(l, []string{"C1"}, "CarContract:GetCarsByStatus", string(StatusScrapped))
+
+	l.mustFail(testManufacturer, nil, "forbidden", "CarContract:DeleteCar", "C2")
+	l.mustInvoke(testAdmin, nil, "CarContract:DeleteCar", "C2")
+	for _, key := range [][]string{{carAttributesIndex, "Maruti", "Alto", "Red", "C2"}, {carOwnerIndex, "dani", "C2"}, {carStatusIndex, string(StatusRegistered), "C2"}, {carPlateIndex, "KA01", "C2"}} {
+		if l.hasKey(key[0], key[1:]...) {
+			t.Fatalf("%v is left after deleting C2", key)
+		}
+	}
+	checkCarIDs(l, []string{}, "CarContract:GetAllCars")
+}
+
+func TestRebuildCarIndexes(t *testing.T) {
+	l := newTestLedger(t)
+	// cars written by versions of the chaincode without indexes
+	legacy := map[string]string{
+		"L1": `{"assetType":"car","carId":"L1","make":"Maruti","model":"Alto","color":"Red","ownedBy":"Dani","status":"Registered to Dani with plate number KA01","dateOfManufacture":"25/10/2019"}`,
+		"L2": `{"assetType":"car","carId":"L2","make":"Maruti","model":"Alto","color":"Red","ownedBy":"Eve","status":"Registered to Eve with plate number KA01","dateOfManufacture":"25/10/2019"}`,
+		"L3": `{"assetType":"car","carId":"L3","make":"Maruti","model":"Swift","color":"Blue","ownedBy":"fac","status":"In Factory","dateOfManufacture":"25/10/2019"}`,
+	}
+	for carID, value := range legacy {
+		l.state[carID] = []byte(value)
+	}
+	checkCarIDs(l, []string{}, "CarContract:GetAllCars")
+
+	l.mustFail(testManufacturer, nil, "forbidden", "CarContract:RebuildIndexes", "2", "")
+	var page IndexRebuildResult
+	if err := json.Unmarshal([]byte(l.mustInvoke(testAdmin, nil, "CarContract:RebuildIndexes", "2", "")), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Indexed != 2 || page.Bookmark == "" {
+		t.Fatalf("first page = %+v, want 2 cars and a bookmark", page)
+	}
+	if err := json.Unmarshal([]byte(l.mustInvoke(testAdmin, nil, "CarContract:RebuildIndexes", "2", page.Bookmark)), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Indexed != 1 || page.Bookmark != "" {
+		t.Fatalf("last page = %+v, want 1 car and no bookmark", page)
+	}
+
+	checkCarIDs(l, []string{"L3", "L2", "L1"}, "CarContract:GetAllCars")
+	checkCarIDs(l, []string{"L1", "L2"}, "CarContract:GetCarsByStatus", string(StatusRegistered))
+	checkCarIDs(l, []string{"L3"}, "CarContract:GetCarsByAttributes", "Maruti", "Swift", "")
+	if !l.hasKey(carPlateIndex, "KA01", "L1") || l.hasKey(carPlateIndex, "KA01", "L2") {
+		t.Fatal("the plate KA01 must only be indexed for L1")
+	}
+}
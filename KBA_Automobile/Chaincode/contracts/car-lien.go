@@ -0,0 +1,245 @@
+package contracts
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Lien is a financing claim of a lender on a car. The lien ID is the ID of the
+// transaction that placed it. A lien only takes effect once the owner of the
+// car or the RTO has approved it.
+type Lien struct {
+	LienId        string     `json:"lienId"`
+	Lender        *Submitter `json:"lender"`
+	LoanReference string     `json:"loanReference"`
+	PlacedAt      string     `json:"placedAt"`
+	ApprovedBy    *Submitter `json:"approvedBy,omitempty" metadata:",optional"`
+	ApprovedAt    string     `json:"approvedAt,omitempty" metadata:",optional"`
+	ReleasedAt    string     `json:"releasedAt,omitempty" metadata:",optional"`
+	ReleaseTxId   string     `json:"releaseTxId,omitempty" metadata:",optional"`
+}
+
+// PlaceLien requests a lien of the calling lender against a car. The lien
+// waits on the car as its pendingLien until the owner or the RTO approves it
+// with ApproveLien, so that no lender can encumber a car without consent.
+func (c *CarContract) PlaceLien(ctx contractapi.TransactionContextInterface, carID string, loanReference string) (*Car, error) {
+	if err := requireRole(ctx, RoleLender); err != nil {
+		return nil, err
+	}
+
+	loanReference = strings.TrimSpace(loanReference)
+	if loanReference == "" {
+		return nil, fmt.Errorf("a loan reference is required to place a lien on car %s", carID)
+	}
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+	if car.Status == StatusScrapped {
+		return nil, fmt.Errorf("car %s is %s, no lien can be placed on it", carID, car.Status)
+	}
+	if err := checkLien(car); err != nil {
+		return nil, err
+	}
+	if car.PendingLien != nil {
+		return nil, fmt.Errorf("car %s already has a lien of %s awaiting approval", carID, car.PendingLien.Lender.Name)
+	}
+
+	lender, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	placedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	car.PendingLien = &Lien{
+		LienId:        ctx.GetStub().GetTxID(),
+		Lender:        lender,
+		LoanReference: loanReference,
+		PlacedAt:      placedAt,
+	}
+
+	err = putCarAnnotation(ctx, car, annotationLiens)
+	if err != nil {
+		return nil, err
+	}
+	err = emitCarEvent(ctx, EventLienRequested, car)
+	if err != nil {
+		return nil, err
+	}
+	return car, nil
+}
+
+// ApproveLien gives the consent of the owner of the car, or the approval of
+// the RTO, to the pending lien, which becomes the active lien of the car.
+// While the lien is active the car cannot change owner or registration
+// number, nor be scrapped or deleted.
+func (c *CarContract) ApproveLien(ctx contractapi.TransactionContextInterface, carID string) (*Car, error) {
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer, RoleOwner, RoleRTO); err != nil {
+		return nil, err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+	if car.PendingLien == nil {
+		return nil, fmt.Errorf("car %s has no lien awaiting approval", carID)
+	}
+	if car.Status == StatusScrapped {
+		return nil, fmt.Errorf("car %s is %s, no lien can be placed on it", carID, car.Status)
+	}
+	approver, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := canApproveLien(ctx, car, approver)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("forbidden: only the owner of car %s or the RTO can approve a lien on it", carID)
+	}
+
+	approvedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	car.Lien = car.PendingLien
+	car.Lien.ApprovedBy = approver
+	car.Lien.ApprovedAt = approvedAt
+	car.PendingLien = nil
+
+	err = putCarAnnotation(ctx, car, annotationLiens)
+	if err != nil {
+		return nil, err
+	}
+	err = emitCarEvent(ctx, EventLienPlaced, car)
+	if err != nil {
+		return nil, err
+	}
+	return car, nil
+}
+
+// DeclineLien drops the pending lien of a car. The owner and the RTO may
+// decline it, and the lender who placed it may withdraw it.
+func (c *CarContract) DeclineLien(ctx contractapi.TransactionContextInterface, carID string) (*Car, error) {
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer, RoleOwner, RoleRTO, RoleLender); err != nil {
+		return nil, err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+	if car.PendingLien == nil {
+		return nil, fmt.Errorf("car %s has no lien awaiting approval", carID)
+	}
+	caller, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ok := *caller == *car.PendingLien.Lender
+	if !ok {
+		ok, err = canApproveLien(ctx, car, caller)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("forbidden: only the owner of car %s, the RTO or the lender can decline the pending lien", carID)
+	}
+
+	car.PendingLien = nil
+	err = putCarAnnotation(ctx, car, annotationLiens)
+	if err != nil {
+		return nil, err
+	}
+	err = emitCarEvent(ctx, EventLienDeclined, car)
+	if err != nil {
+		return nil, err
+	}
+	return car, nil
+}
+
+// ReleaseLien lifts the active lien on a car. Only the lender who placed the
+// lien may release it. The released lien is kept in the lien history of the
+// car.
+func (c *CarContract) ReleaseLien(ctx contractapi.TransactionContextInterface, carID string) (*Car, error) {
+	if err := requireRole(ctx, RoleLender); err != nil {
+		return nil, err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+	if car.Lien == nil {
+		return nil, fmt.Errorf("car %s has no active lien", carID)
+	}
+	lender, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if *lender != *car.Lien.Lender {
+		return nil, fmt.Errorf("forbidden: only the lender %s can release the lien on car %s", car.Lien.Lender.Name, carID)
+	}
+
+	releasedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	car.Lien.ReleasedAt = releasedAt
+	car.Lien.ReleaseTxId = ctx.GetStub().GetTxID()
+	car.LienHistory = append(car.LienHistory, car.Lien)
+	car.Lien = nil
+
+	err = putCarAnnotation(ctx, car, annotationLiens)
+	if err != nil {
+		return nil, err
+	}
+	err = emitCarEvent(ctx, EventLienReleased, car)
+	if err != nil {
+		return nil, err
+	}
+	return car, nil
+}
+
+// GetLienHistory returns every lien placed on a car, oldest first. The active
+// lien, if any, is the last one and has no release time.
+func (c *CarContract) GetLienHistory(ctx contractapi.TransactionContextInterface, carID string) ([]*Lien, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+	liens := append([]*Lien{}, car.LienHistory...)
+	if car.Lien != nil {
+		liens = append(liens, car.Lien)
+	}
+	return liens, nil
+}
+
+// canApproveLien returns true when the caller may consent to a lien on the
+// car: the RTO, or whoever is in charge of the car as its owner
+func canApproveLien(ctx contractapi.TransactionContextInterface, car *Car, caller *Submitter) (bool, error) {
+	isRTO, err := hasRole(ctx, RoleRTO)
+	if err != nil || isRTO {
+		return isRTO, err
+	}
+	return canManageCar(ctx, car, caller)
+}
+
+// checkLien returns an error when the car has an active lien
+func checkLien(car *Car) error {
+	if car.Lien != nil {
+		return fmt.Errorf("car %s has an active lien held by %s, it must be released first", car.CarId, car.Lien.Lender.Name)
+	}
+	return nil
+}
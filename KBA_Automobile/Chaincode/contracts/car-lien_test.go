@@ -0,0 +1,67 @@
+package contracts
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+)
+
+// testLender is a lender of the lenders' org, which SetRoleMSPs must trust
+var testLender = testIdentity{"Org5MSP", RoleLender, "bank"}
+
+// checkLienEndorsement fails the test unless the lien key of the car requires
+// a peer of the org
+func checkLienEndorsement(l *testLedger, carID string, mspID string) {
+	l.t.Helper()
+	key, _ := shim.CreateCompositeKey(carAnnotationIndex, []string{carID, annotationLiens})
+	want, err := ownerEndorsementPolicy(mspID)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	if !bytes.Equal(l.validationParameters[key], want) {
+		l.t.Fatalf("the liens of car %s do not require a peer of %s", carID, mspID)
+	}
+}
+
+func TestLien(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke(testAdmin, nil, "AccessContract:SetRoleMSPs", RoleLender, `["Org5MSP"]`)
+	l.mustInvoke(testAdmin, nil, "AccessContract:SetRoleMSPs", RoleOwner, `["Org3MSP","Org6MSP"]`)
+	addDealer(l, testDealer)
+	deliverCar(l, testDealer, "C1")
+	dani := testOwner("dani")
+	sellCar(l, testDealer, dani, "C1", "KA01")
+
+	l.mustFail(testLender, nil, "loan reference is required", "CarContract:PlaceLien", "C1", " ")
+	l.mustInvoke(testLender, nil, "CarContract:PlaceLien", "C1", "loan-1")
+	checkLienEndorsement(l, "C1", dani.mspID)
+	l.mustFail(testOwner("eve"), nil, "forbidden", "CarContract:ApproveLien", "C1")
+	// a pending lien does not lock the car
+	l.mustInvoke(dani, nil, "CarContract:ProposeTransfer", "C1", "eve", dani.mspID)
+	l.mustInvoke(dani, nil, "CarContract:CancelTransfer", "C1")
+	l.mustInvoke(dani, nil, "CarContract:ApproveLien", "C1")
+	if l.lastEvent() != EventLienPlaced {
+		t.Fatalf("event = %s, want %s", l.lastEvent(), EventLienPlaced)
+	}
+
+	l.mustFail(dani, nil, "active lien", "CarContract:ProposeTransfer", "C1", "eve", dani.mspID)
+	l.mustFail(testRTO, nil, "active lien", "CarContract:ReRegisterCar", "C1", "KA02", "moved")
+	l.mustFail(dani, nil, "active lien", "CarContract:ScrapCar", "C1", "wrecked", "cert-1")
+	l.mustFail(testIdentity{testLender.mspID, RoleLender, "other"}, nil, "forbidden", "CarContract:ReleaseLien", "C1")
+	l.mustInvoke(testLender, nil, "CarContract:ReleaseLien", "C1")
+
+	var liens []*Lien
+	if err := json.Unmarshal([]byte(l.mustInvoke(dani, nil, "CarContract:GetLienHistory", "C1")), &liens); err != nil {
+		t.Fatal(err)
+	}
+	if len(liens) != 1 || liens[0].ReleasedAt == "" || liens[0].ApprovedBy.Name != dani.name {
+		t.Fatalf("lien history = %+v, want the released lien approved by %s", liens, dani.name)
+	}
+
+	// the liens follow the car to its new owner's org
+	finn := testIdentity{"Org6MSP", RoleOwner, "finn"}
+	sellCar(l, dani, finn, "C1", "")
+	checkLienEndorsement(l, "C1", finn.mspID)
+}
@@ -0,0 +1,203 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// matchCandidatesIndex is the composite key object type of the candidates of
+// automatic matches in the implicit collection of the manufacturer's org
+const matchCandidatesIndex = "matchCandidates~carId"
+
+// MatchDecision records how a car was matched to an order. It is public, so
+// it only names the chosen order; the candidates of an automatic match are
+// kept in the manufacturer's private data, see GetMatchCandidates.
+type MatchDecision struct {
+	OrderId   string     `json:"orderId"`
+	Automatic bool       `json:"automatic"`
+	DecidedBy *Submitter `json:"decidedBy"`
+	DecidedAt string     `json:"decidedAt"`
+	TxId      string     `json:"txId"`
+}
+
+// MatchCandidates are the pending orders that matched a car when it was
+// matched automatically, in the order they were ranked; the first one was
+// chosen. They name the orders of every dealer, so they are stored in the
+// implicit collection of the manufacturer's org and only their hash is public.
+type MatchCandidates struct {
+	CarId      string   `json:"carId"`
+	OrderId    string   `json:"orderId"`
+	Candidates []string `json:"candidates"`
+	TxId       string   `json:"txId"`
+}
+
+// AutoMatch assigns the car to the oldest pending order for its make, model
+// and color, see GetMatchingOrders for the ranking. The ranking only depends on
+// the ledger, so every endorsing peer picks the same order.
+func (c *CarContract) AutoMatch(ctx contractapi.TransactionContextInterface, carID string) (string, error) {
+	if err := requireRole(ctx, RoleManufacturer); err != nil {
+		return "", err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return "", err
+	}
+	order, err := autoMatchCar(ctx, car)
+	if err != nil {
+		return "", err
+	}
+	if order == nil {
+		return "", fmt.Errorf("no pending order matches car %s", carID)
+	}
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return "", err
+	}
+	err = emitOrderEvent(ctx, EventOrderMatched, order.OrderID, carID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Matched order %v and Assigned %v to %v", order.OrderID, carID, order.DealerName), nil
+}
+
+// autoMatchCar matches the car to the first of the ranked pending orders for
+// its make, model and color and returns that order, or nil when no order
+// matches. The caller writes the car.
+func autoMatchCar(ctx contractapi.TransactionContextInterface, car *Car) (*Order, error) {
+	orders, err := getOrdersByAttributes(ctx, car.Make, car.Model, car.Color)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	rankOrders(orders)
+
+	candidates := make([]string, len(orders))
+	for i, order := range orders {
+		candidates[i] = order.OrderID
+	}
+	err = matchCarToOrder(ctx, car, orders[0], candidates)
+	if err != nil {
+		return nil, err
+	}
+	return orders[0], nil
+}
+
+// matchCarToOrder assigns the car to the dealer of the order, allocates it to
+// the order and records the decision on the car. candidates is nil for
+// manual matches. The order is written; the caller writes the car.
+func matchCarToOrder(ctx contractapi.TransactionContextInterface, car *Car, order *Order, candidates []string) error {
+	if car.Make != order.Make || car.Color != order.Color || car.Model != order.Model {
+		return fmt.Errorf("order is not matching")
+	}
+	if err := transitionCar(car, StatusAssignedToDealer); err != nil {
+		return err
+	}
+	if err := allocateCar(ctx, order, car.CarId); err != nil {
+		return err
+	}
+	err := changeOwner(ctx, car, order.DealerName, order.DealerMspId)
+	if err != nil {
+		return err
+	}
+
+	decidedBy, err := getSubmitter(ctx)
+	if err != nil {
+		return err
+	}
+	decidedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	car.OrderId = order.OrderID
+	car.MatchDecision = &MatchDecision{
+		OrderId:   order.OrderID,
+		Automatic: candidates != nil,
+		DecidedBy: decidedBy,
+		DecidedAt: decidedAt,
+		TxId:      ctx.GetStub().GetTxID(),
+	}
+	if candidates != nil {
+		err = putMatchCandidates(ctx, decidedBy.MspId, &MatchCandidates{
+			CarId:      car.CarId,
+			OrderId:    order.OrderID,
+			Candidates: candidates,
+			TxId:       ctx.GetStub().GetTxID(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return putOrder(ctx, order)
+}
+
+// putMatchCandidates writes the candidates of an automatic match to the
+// implicit collection of the given org
+func putMatchCandidates(ctx contractapi.TransactionContextInterface, mspID string, candidates *MatchCandidates) error {
+	key, err := ctx.GetStub().CreateCompositeKey(matchCandidatesIndex, []string{candidates.CarId})
+	if err != nil {
+		return err
+	}
+	value, _ := json.Marshal(candidates)
+	err = ctx.GetStub().PutPrivateData(implicitCollection(mspID), key, value)
+	if err != nil {
+		return fmt.Errorf("failed to put to private data collection. %v", err)
+	}
+	return nil
+}
+
+// GetMatchCandidates returns the ranked candidates of the last automatic match
+// of a car. They are kept in the implicit collection of the manufacturer's
+// org, so it must be sent to a peer of that org.
+func (c *CarContract) GetMatchCandidates(ctx contractapi.TransactionContextInterface, carID string) (*MatchCandidates, error) {
+	if err := requireRole(ctx, RoleManufacturer); err != nil {
+		return nil, err
+	}
+
+	caller, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(matchCandidatesIndex, []string{carID})
+	if err != nil {
+		return nil, err
+	}
+	value, err := ctx.GetStub().GetPrivateData(implicitCollection(caller.MspId), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from private data collection: %v", err)
+	}
+	if value == nil {
+		return nil, fmt.Errorf("car %s has no automatic match recorded by %s", carID, caller.MspId)
+	}
+
+	var candidates MatchCandidates
+	err = json.Unmarshal(value, &candidates)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal private data collection data to type MatchCandidates")
+	}
+	return &candidates, nil
+}
+
+// autoMatchRequested reads the optional transient field autoMatch of
+// CreateCar
+func autoMatchRequested(ctx contractapi.TransactionContextInterface) (bool, error) {
+	transientData, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return false, err
+	}
+	value, exists := transientData["autoMatch"]
+	if !exists {
+		return false, nil
+	}
+	autoMatch, err := strconv.ParseBool(string(value))
+	if err != nil {
+		return false, fmt.Errorf("autoMatch takes true or false, got %q", value)
+	}
+	return autoMatch, nil
+}
@@ -0,0 +1,111 @@
+package contracts
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Sources of odometer readings
+const (
+	OdometerSourceReading = "reading"
+	OdometerSourceService = "service"
+)
+
+// OdometerReading is one entry of the odometer timeline of a car. A reading
+// lower than the last accepted one is kept in the timeline, marked as a
+// suspected rollback, but does not become the current odometer value.
+type OdometerReading struct {
+	Value      int        `json:"value"`
+	Source     string     `json:"source"`
+	RecordedBy *Submitter `json:"recordedBy"`
+	RecordedAt string     `json:"recordedAt"`
+	TxId       string     `json:"txId"`
+	Rollback   bool       `json:"rollback,omitempty" metadata:",optional"`
+}
+
+// RecordOdometerReading adds an odometer reading to the timeline of a car.
+// Readings must not go down: a value below the last accepted reading is
+// recorded as a suspected rollback, flags the car and raises the
+// OdometerRollbackSuspected event instead of OdometerRecorded.
+func (c *CarContract) RecordOdometerReading(ctx contractapi.TransactionContextInterface, carID string, value int) (*Car, error) {
+	if err := requireRole(ctx, RoleDealer, RoleServiceCenter, RoleRTO); err != nil {
+		return nil, err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+	rollback, err := recordOdometer(ctx, car, value, OdometerSourceReading)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putCarAnnotation(ctx, car, annotationOdometer)
+	if err != nil {
+		return nil, err
+	}
+	event := EventOdometerRecorded
+	if rollback {
+		event = EventOdometerRollbackSuspected
+	}
+	err = emitCarEvent(ctx, event, car)
+	if err != nil {
+		return nil, err
+	}
+	return car, nil
+}
+
+// GetOdometerTimeline returns the odometer readings of a car, oldest first
+func (c *CarContract) GetOdometerTimeline(ctx contractapi.TransactionContextInterface, carID string) ([]*OdometerReading, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+	if car.OdometerReadings == nil {
+		return []*OdometerReading{}, nil
+	}
+	return car.OdometerReadings, nil
+}
+
+// recordOdometer appends a reading to the odometer timeline of the car and
+// returns true when it is lower than the last accepted reading. The caller
+// writes the odometer annotation of the car.
+func recordOdometer(ctx contractapi.TransactionContextInterface, car *Car, value int, source string) (bool, error) {
+	if car.Status == StatusScrapped {
+		return false, fmt.Errorf("car %s is %s and takes no more odometer readings", car.CarId, car.Status)
+	}
+	if value < 0 {
+		return false, fmt.Errorf("odometer reading must not be negative, got %d", value)
+	}
+
+	recordedBy, err := getSubmitter(ctx)
+	if err != nil {
+		return false, err
+	}
+	recordedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	reading := &OdometerReading{
+		Value:      value,
+		Source:     source,
+		RecordedBy: recordedBy,
+		RecordedAt: recordedAt,
+		TxId:       ctx.GetStub().GetTxID(),
+	}
+	if value < car.Odometer {
+		reading.Rollback = true
+		car.SuspectedRollback = true
+	} else {
+		car.Odometer = value
+	}
+	car.OdometerReadings = append(car.OdometerReadings, reading)
+	return reading.Rollback, nil
+}
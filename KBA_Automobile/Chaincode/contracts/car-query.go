@@ -0,0 +1,144 @@
+package contracts
+
+import (
+	"fmt"
+	"strconv"
+
+	"couchquery"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// queryableCarFields are the car fields QueryCars can filter and sort on,
+// with the JSON type of their values
+var queryableCarFields = map[string]string{
+	"carId":              "string",
+	"make":               "string",
+	"model":              "string",
+	"color":              "string",
+	"ownedBy":            "string",
+	"status":             "string",
+	"dateOfManufacture":  "string",
+	"vin":                "string",
+	"manufacturerCode":   "string",
+	"modelYear":          "number",
+	"registrationNumber": "string",
+	"registrationDate":   "string",
+}
+
+// CarCondition is one condition of a car filter, e.g. modelYear $gte 2020.
+// Operators are $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin and $exists; $in
+// and $nin take values, $exists takes the value true or false.
+type CarCondition struct {
+	Field    string   `json:"field"`
+	Operator string   `json:"operator"`
+	Value    string   `json:"value,omitempty" metadata:",optional"`
+	Values   []string `json:"values,omitempty" metadata:",optional"`
+}
+
+// CarFilter selects cars for QueryCars. All conditions must hold. Sorting
+// needs a CouchDB index on the sort field, see META-INF/statedb.
+type CarFilter struct {
+	Conditions []*CarCondition `json:"conditions"`
+	SortBy     string          `json:"sortBy,omitempty" metadata:",optional"`
+	Descending bool            `json:"descending,omitempty" metadata:",optional"`
+	Limit      int             `json:"limit,omitempty" metadata:",optional"`
+}
+
+// QueryCars returns the cars matching the filter. Scrapped cars are left out
+// unless the filter has a condition on status. Requires CouchDB.
+func (c *CarContract) QueryCars(ctx contractapi.TransactionContextInterface, filter *CarFilter) ([]*Car, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+
+	queryString, err := buildCarQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	cars, err := carResultIteratorFunction(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+	if cars == nil {
+		cars = []*Car{}
+	}
+	return cars, nil
+}
+
+// buildCarQuery turns a car filter into a CouchDB query
+func buildCarQuery(filter *CarFilter) (string, error) {
+	if len(filter.Conditions) == 0 {
+		return "", fmt.Errorf("a car filter needs at least one condition")
+	}
+
+	query := couchquery.New().Eq("assetType", "car")
+	for _, condition := range filter.Conditions {
+		value, err := carConditionValue(condition)
+		if err != nil {
+			return "", err
+		}
+		query.Where(condition.Field, condition.Operator, value)
+	}
+	if !query.Has("status") {
+		query.Where("status", couchquery.Ne, string(StatusScrapped))
+	}
+
+	if filter.SortBy != "" {
+		if _, ok := queryableCarFields[filter.SortBy]; !ok {
+			return "", fmt.Errorf("cars cannot be sorted by %s", filter.SortBy)
+		}
+		query.Sort(filter.SortBy, filter.Descending)
+	}
+	if filter.Limit != 0 {
+		query.Limit(filter.Limit)
+	}
+	return query.Build()
+}
+
+// carConditionValue checks the field of a condition and converts its value
+// to the JSON type of the field
+func carConditionValue(condition *CarCondition) (interface{}, error) {
+	kind, ok := queryableCarFields[condition.Field]
+	if !ok {
+		return nil, fmt.Errorf("cars cannot be filtered by %q", condition.Field)
+	}
+
+	convert := func(value string) (interface{}, error) {
+		if kind != "number" {
+			return value, nil
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s takes a number, got %q", condition.Field, value)
+		}
+		return number, nil
+	}
+
+	switch condition.Operator {
+	case couchquery.In, couchquery.Nin:
+		values := make([]interface{}, len(condition.Values))
+		for i, value := range condition.Values {
+			converted, err := convert(value)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = converted
+		}
+		return values, nil
+	case couchquery.Exists:
+		exists, err := strconv.ParseBool(condition.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s takes true or false, got %q", couchquery.Exists, condition.Value)
+		}
+		return exists, nil
+	}
+	return convert(condition.Value)
+}
@@ -0,0 +1,242 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// retiredPlateIndex is the composite key object type of retired registration
+// numbers. A plate that was retired is never issued again.
+const retiredPlateIndex = "retiredPlate~plate"
+
+// platePattern matches a normalized registration number
+var platePattern = regexp.MustCompile(`^[A-Z0-9]{1,15}$`)
+
+// RegistrationRecord is a registration number a car held in the past
+type RegistrationRecord struct {
+	RegistrationNumber string `json:"registrationNumber"`
+	RegisteredAt       string `json:"registeredAt"`
+	RetiredAt          string `json:"retiredAt"`
+	Reason             string `json:"reason"`
+}
+
+// RetiredPlate records which car a retired registration number belonged to
+type RetiredPlate struct {
+	RegistrationNumber string `json:"registrationNumber"`
+	CarId              string `json:"carId"`
+	RetiredAt          string `json:"retiredAt"`
+	Reason             string `json:"reason"`
+	TxId               string `json:"txId"`
+}
+
+// normalizePlate upper-cases a registration number and drops the spaces and
+// dashes, so that "KA 01-AB 1234" and "ka01ab1234" are the same plate
+func normalizePlate(registrationNumber string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(registrationNumber)))
+}
+
+// checkPlate normalizes a registration number and checks that it can be
+// issued to the car: it must be well formed, must not have been retired and
+// must not be held by another car
+func checkPlate(ctx contractapi.TransactionContextInterface, registrationNumber string, carID string) (string, error) {
+	plate := normalizePlate(registrationNumber)
+	if !platePattern.MatchString(plate) {
+		return "", fmt.Errorf("registration number %s must be 1 to 15 letters and digits", registrationNumber)
+	}
+
+	retired, err := getRetiredPlate(ctx, plate)
+	if err != nil {
+		return "", err
+	}
+	if retired != nil {
+		return "", fmt.Errorf("registration number %s was retired from car %s on %s and cannot be issued again", plate, retired.CarId, retired.RetiredAt)
+	}
+
+	holder, err := getCarIDByPlate(ctx, plate)
+	if err != nil {
+		return "", err
+	}
+	if holder != "" && holder != carID {
+		return "", fmt.Errorf("registration number %s is already assigned to car %s", plate, holder)
+	}
+	return plate, nil
+}
+
+// retirePlate takes the current registration number off the car, moves it to
+// the previous registrations and marks the plate as retired. The caller
+// writes the car, which drops its plate index key.
+func retirePlate(ctx contractapi.TransactionContextInterface, car *Car, reason string) error {
+	if car.RegistrationNumber == "" {
+		return nil
+	}
+	plate := normalizePlate(car.RegistrationNumber)
+
+	retiredAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(retiredPlateIndex, []string{plate})
+	if err != nil {
+		return err
+	}
+	bytes, _ := json.Marshal(RetiredPlate{
+		RegistrationNumber: plate,
+		CarId:              car.CarId,
+		RetiredAt:          retiredAt,
+		Reason:             reason,
+		TxId:               ctx.GetStub().GetTxID(),
+	})
+	if err := ctx.GetStub().PutState(key, bytes); err != nil {
+		return err
+	}
+
+	car.PreviousRegistrations = append(car.PreviousRegistrations, &RegistrationRecord{
+		RegistrationNumber: plate,
+		RegisteredAt:       car.RegistrationDate,
+		RetiredAt:          retiredAt,
+		Reason:             reason,
+	})
+	car.RegistrationNumber = ""
+	car.RegistrationDate = ""
+	return nil
+}
+
+// ReRegisterCar gives a registered car a new registration number, e.g. when
+// it moves to another state. The old number is retired.
+func (c *CarContract) ReRegisterCar(ctx contractapi.TransactionContextInterface, carID string, registrationNumber string, reason string) (string, error) {
+	if err := requireRole(ctx, RoleRTO); err != nil {
+		return "", err
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", fmt.Errorf("a reason is required to re-register car %s", carID)
+	}
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return "", err
+	}
+	if car.Status != StatusRegistered {
+		return "", fmt.Errorf("car %s must be %s to be re-registered, it is %s", carID, StatusRegistered, car.Status)
+	}
+	if car.PendingTransfer != nil {
+		return "", fmt.Errorf("car %s has a pending transfer to %s, it cannot be re-registered", carID, car.PendingTransfer.Buyer)
+	}
+	if err := checkBlockingRecalls(car); err != nil {
+		return "", err
+	}
+	if err := checkLien(car); err != nil {
+		return "", err
+	}
+
+	plate, err := checkPlate(ctx, registrationNumber, carID)
+	if err != nil {
+		return "", err
+	}
+	if plate == normalizePlate(car.RegistrationNumber) {
+		return "", fmt.Errorf("car %s is already registered with number %s", carID, plate)
+	}
+
+	err = retirePlate(ctx, car, reason)
+	if err != nil {
+		return "", err
+	}
+	registrationDate, err := getTxTimestamp(ctx)
+	if err != nil {
+		return "", err
+	}
+	car.RegistrationNumber = plate
+	car.RegistrationDate = registrationDate
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return "", err
+	}
+	err = emitCarEvent(ctx, EventCarReRegistered, car)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Car %v re-registered with number %v", carID, plate), nil
+}
+
+// GetCarByRegistration retrieves the car currently registered with the given
+// number. Spaces, dashes and case are ignored.
+func (c *CarContract) GetCarByRegistration(ctx contractapi.TransactionContextInterface, registrationNumber string) (*Car, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+
+	plate := normalizePlate(registrationNumber)
+	carID, err := getCarIDByPlate(ctx, plate)
+	if err != nil {
+		return nil, err
+	}
+	if carID != "" {
+		return readCar(ctx, carID)
+	}
+
+	retired, err := getRetiredPlate(ctx, plate)
+	if err != nil {
+		return nil, err
+	}
+	if retired != nil {
+		return nil, fmt.Errorf("registration number %s was retired from car %s on %s", plate, retired.CarId, retired.RetiredAt)
+	}
+	return nil, fmt.Errorf("no car is registered with number %s", plate)
+}
+
+// getCarIDByPlate returns the ID of the car holding the plate, or an empty
+// string when no car holds it
+func getCarIDByPlate(ctx contractapi.TransactionContextInterface, plate string) (string, error) {
+	stub := ctx.GetStub()
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(carPlateIndex, []string{plate})
+	if err != nil {
+		return "", err
+	}
+	defer resultsIterator.Close()
+
+	if !resultsIterator.HasNext() {
+		return "", nil
+	}
+	queryResult, err := resultsIterator.Next()
+	if err != nil {
+		return "", err
+	}
+	_, keyParts, err := stub.SplitCompositeKey(queryResult.Key)
+	if err != nil {
+		return "", err
+	}
+	return keyParts[len(keyParts)-1], nil
+}
+
+// getRetiredPlate returns the retirement record of a plate, or nil when the
+// plate was never retired
+func getRetiredPlate(ctx contractapi.TransactionContextInterface, plate string) (*RetiredPlate, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(retiredPlateIndex, []string{plate})
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if bytes == nil {
+		return nil, nil
+	}
+
+	var retired RetiredPlate
+	err = json.Unmarshal(bytes, &retired)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal world state data to type RetiredPlate")
+	}
+	return &retired, nil
+}
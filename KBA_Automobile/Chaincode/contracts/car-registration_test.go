@@ -0,0 +1,43 @@
+package contracts
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRegistrationNumbers(t *testing.T) {
+	l := newTestLedger(t)
+	addDealer(l, testDealer)
+	for _, carID := range []string{"C1", "C2", "C3"} {
+		deliverCar(l, testDealer, carID)
+	}
+	dani := testOwner("dani")
+	sellCar(l, testDealer, dani, "C1", "ka 01-ab 1234")
+
+	var car Car
+	if err := json.Unmarshal([]byte(l.mustInvoke(testRTO, nil, "CarContract:GetCarByRegistration", "KA01AB1234")), &car); err != nil {
+		t.Fatal(err)
+	}
+	if car.CarId != "C1" || car.RegistrationNumber != "KA01AB1234" {
+		t.Fatalf("KA01AB1234 is held by %s as %s, want C1", car.CarId, car.RegistrationNumber)
+	}
+
+	l.mustInvoke(testDealer, nil, "CarContract:ProposeTransfer", "C2", "eve", dani.mspID)
+	l.mustInvoke(testOwner("eve"), nil, "CarContract:AcceptTransfer", "C2")
+	agreePrice(l, testDealer, "C2", "500000", "t2")
+	agreePrice(l, testOwner("eve"), "C2", "500000", "t2")
+	l.mustFail(testRTO, nil, "already assigned to car C1", "CarContract:RegisterCar", "C2", "eve", dani.mspID, "KA01AB1234")
+	l.mustFail(testRTO, nil, "1 to 15 letters and digits", "CarContract:RegisterCar", "C2", "eve", dani.mspID, "x!")
+
+	l.mustInvoke(testRTO, nil, "CarContract:ReRegisterCar", "C1", "MH02CD5678", "moved")
+	l.mustFail(testRTO, nil, "was retired from car C1", "CarContract:RegisterCar", "C2", "eve", dani.mspID, "KA01AB1234")
+
+	// deleting a car retires its number
+	l.mustInvoke(testAdmin, nil, "CarContract:DeleteCar", "C1")
+	if l.hasKey(carPlateIndex, "MH02CD5678", "C1") {
+		t.Fatal("the plate index of the deleted car is left")
+	}
+	l.mustFail(testRTO, nil, "was retired from car C1", "CarContract:GetCarByRegistration", "MH02CD5678")
+	l.mustFail(testRTO, nil, "was retired from car C1", "CarContract:RegisterCar", "C2", "eve", dani.mspID, "MH02CD5678")
+	l.mustInvoke(testRTO, nil, "CarContract:RegisterCar", "C2", "eve", dani.mspID, "KA03")
+}
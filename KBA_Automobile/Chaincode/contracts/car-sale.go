@@ -0,0 +1,220 @@
+package contracts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// saleAgreementIndex is the composite key object type of sale agreements in
+// the implicit collections. Each party has its own key, so that the seller and
+// the buyer may belong to the same org.
+const saleAgreementIndex = "saleAgreement~carId~party"
+
+// SaleAgreement is the price one party of a sale agreed to. It only ever
+// lives in the implicit private data collection of the party's org. The
+// seller and the buyer write the same agreement to their own collections and
+// ApproveTransfer, or RegisterCar for a dealer's sale to a customer, compares
+// the hashes. The trade ID is a secret both parties
+// choose, so that the public hash cannot be matched against guessed prices.
+type SaleAgreement struct {
+	CarId   string `json:"carId"`
+	Seller  string `json:"seller"`
+	Buyer   string `json:"buyer"`
+	Price   int64  `json:"price"`
+	TradeId string `json:"tradeId"`
+}
+
+// implicitCollection returns the name of the implicit private data collection
+// of an org
+func implicitCollection(mspID string) string {
+	return "_implicit_org_" + mspID
+}
+
+// AgreeSalePrice records the sale price of a pending transfer in the implicit
+// collection of the caller's org. The seller and the buyer each call it with
+// the price and the trade ID in the transient fields "price" and "tradeId";
+// the transfer can only be approved, or the car registered to the buyer, once
+// both have agreed to the same values.
+func (c *CarContract) AgreeSalePrice(ctx contractapi.TransactionContextInterface, carID string) (string, error) {
+	if err := requireRole(ctx, RoleOwner, RoleDealer); err != nil {
+		return "", err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return "", err
+	}
+	transfer := car.PendingTransfer
+	if transfer == nil {
+		return "", fmt.Errorf("car %s has no pending transfer", carID)
+	}
+	party, err := getSubmitter(ctx)
+	if err != nil {
+		return "", err
+	}
+	isSeller := isParty(party, transfer.Seller, transfer.SellerMspId)
+	if !isSeller && !isParty(party, transfer.Buyer, transfer.BuyerMspId) {
+		return "", fmt.Errorf("forbidden: only the seller or the buyer can agree to the sale price of car %s", carID)
+	}
+
+	agreement, err := saleAgreementFromTransient(ctx, car)
+	if err != nil {
+		return "", err
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(saleAgreementIndex, []string{carID, party.Name})
+	if err != nil {
+		return "", err
+	}
+	value, _ := json.Marshal(agreement)
+	err = ctx.GetStub().PutPrivateData(implicitCollection(party.MspId), key, value)
+	if err != nil {
+		return "", err
+	}
+
+	agreedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return "", err
+	}
+	if isSeller {
+		transfer.SellerAgreedAt = agreedAt
+	} else {
+		transfer.BuyerAgreedAt = agreedAt
+	}
+	err = putCar(ctx, car)
+	if err != nil {
+		return "", err
+	}
+	err = emitCarEvent(ctx, EventSalePriceAgreed, car)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Sale price of car %v agreed by %v", carID, party.Name), nil
+}
+
+// ReadSaleAgreement returns the sale agreement of the caller's org for a car.
+// It must be sent to a peer of the caller's org.
+func (c *CarContract) ReadSaleAgreement(ctx contractapi.TransactionContextInterface, carID string) (*SaleAgreement, error) {
+	if err := requireRole(ctx, RoleOwner, RoleDealer); err != nil {
+		return nil, err
+	}
+
+	party, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(saleAgreementIndex, []string{carID, party.Name})
+	if err != nil {
+		return nil, err
+	}
+	value, err := ctx.GetStub().GetPrivateData(implicitCollection(party.MspId), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from private data collection: %v", err)
+	}
+	if value == nil {
+		return nil, fmt.Errorf("%s has no sale agreement for car %s", party.Name, carID)
+	}
+
+	var agreement SaleAgreement
+	err = json.Unmarshal(value, &agreement)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal private data collection data to type SaleAgreement")
+	}
+	return &agreement, nil
+}
+
+// saleAgreementFromTransient builds the sale agreement for the pending
+// transfer of the car from the transient data of the transaction
+func saleAgreementFromTransient(ctx contractapi.TransactionContextInterface, car *Car) (*SaleAgreement, error) {
+	transientData, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, err
+	}
+
+	price, exists := transientData["price"]
+	if !exists {
+		return nil, fmt.Errorf("The price was not specified in transient data. Please try again")
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(string(price)), 10, 64)
+	if err != nil || value <= 0 {
+		return nil, fmt.Errorf("the price must be a positive whole number, got %q", price)
+	}
+	tradeID, exists := transientData["tradeId"]
+	if !exists || strings.TrimSpace(string(tradeID)) == "" {
+		return nil, fmt.Errorf("The tradeId was not specified in transient data. Please try again")
+	}
+
+	return &SaleAgreement{
+		CarId:   car.CarId,
+		Seller:  car.PendingTransfer.Seller,
+		Buyer:   car.PendingTransfer.Buyer,
+		Price:   value,
+		TradeId: strings.TrimSpace(string(tradeID)),
+	}, nil
+}
+
+// verifySaleAgreement checks that the seller and the buyer of the pending
+// transfer of the car wrote the same sale agreement to their collections.
+// Only the hashes are read, so the price stays private.
+func verifySaleAgreement(ctx contractapi.TransactionContextInterface, car *Car) error {
+	transfer := car.PendingTransfer
+	if transfer.SellerAgreedAt == "" {
+		return fmt.Errorf("the seller %s has not agreed to a sale price for car %s", transfer.Seller, car.CarId)
+	}
+	if transfer.BuyerAgreedAt == "" {
+		return fmt.Errorf("the buyer %s has not agreed to a sale price for car %s", transfer.Buyer, car.CarId)
+	}
+
+	sellerKey, err := ctx.GetStub().CreateCompositeKey(saleAgreementIndex, []string{car.CarId, transfer.Seller})
+	if err != nil {
+		return err
+	}
+	buyerKey, err := ctx.GetStub().CreateCompositeKey(saleAgreementIndex, []string{car.CarId, transfer.Buyer})
+	if err != nil {
+		return err
+	}
+	sellerHash, err := ctx.GetStub().GetPrivateDataHash(implicitCollection(transfer.SellerMspId), sellerKey)
+	if err != nil {
+		return fmt.Errorf("failed to read the sale agreement hash of %s: %v", transfer.SellerMspId, err)
+	}
+	buyerHash, err := ctx.GetStub().GetPrivateDataHash(implicitCollection(transfer.BuyerMspId), buyerKey)
+	if err != nil {
+		return fmt.Errorf("failed to read the sale agreement hash of %s: %v", transfer.BuyerMspId, err)
+	}
+	if sellerHash == nil || buyerHash == nil || !bytes.Equal(sellerHash, buyerHash) {
+		return fmt.Errorf("the sale agreements of the seller and the buyer of car %s do not match", car.CarId)
+	}
+	return nil
+}
+
+// deleteSaleAgreements removes the sale agreements of the pending transfer of
+// the car from the collections of the parties that agreed
+func deleteSaleAgreements(ctx contractapi.TransactionContextInterface, car *Car) error {
+	transfer := car.PendingTransfer
+	parties := []struct {
+		name   string
+		mspID  string
+		agreed bool
+	}{
+		{transfer.Seller, transfer.SellerMspId, transfer.SellerAgreedAt != ""},
+		{transfer.Buyer, transfer.BuyerMspId, transfer.BuyerAgreedAt != ""},
+	}
+	for _, party := range parties {
+		if !party.agreed {
+			continue
+		}
+		key, err := ctx.GetStub().CreateCompositeKey(saleAgreementIndex, []string{car.CarId, party.name})
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().DelPrivateData(implicitCollection(party.mspID), key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package contracts
+
+import (
+	"strings"
+	"testing"
+)
+
+// agreePrice records the sale price the party agreed to
+func agreePrice(l *testLedger, party testIdentity, carID string, price string, tradeID string) {
+	l.t.Helper()
+	transient := map[string][]byte{"price": []byte(price), "tradeId": []byte(tradeID)}
+	l.mustInvoke(party, transient, "CarContract:AgreeSalePrice", carID)
+}
+
+// sellCar has the seller sell the car to the buyer, who agree to the same
+// price, and the RTO approve the sale, or register the car when plate is set
+func sellCar(l *testLedger, seller testIdentity, buyer testIdentity, carID string, plate string) {
+	l.t.Helper()
+	l.mustInvoke(seller, nil, "CarContract:ProposeTransfer", carID, buyer.name, buyer.mspID)
+	l.mustInvoke(buyer, nil, "CarContract:AcceptTransfer", carID)
+	agreePrice(l, seller, carID, "500000", "trade-"+carID)
+	agreePrice(l, buyer, carID, "500000", "trade-"+carID)
+	if plate == "" {
+		l.mustInvoke(testRTO, nil, "CarContract:ApproveTransfer", carID)
+	} else {
+		l.mustInvoke(testRTO, nil, "CarContract:RegisterCar", carID, buyer.name, buyer.mspID, plate)
+	}
+}
+
+// saleAgreements returns the number of sale agreements in the private data
+func saleAgreements(l *testLedger) int {
+	count := 0
+	for _, collection := range l.private {
+		for key := range collection {
+			if strings.HasPrefix(key, "\x00"+saleAgreementIndex+"\x00") {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestDealerSaleToCustomer(t *testing.T) {
+	l := newTestLedger(t)
+	addDealer(l, testDealer)
+	deliverCar(l, testDealer, "C1")
+	dani := testOwner("dani")
+
+	l.mustFail(testRTO, nil, "no accepted sale", "CarContract:RegisterCar", "C1", dani.name, dani.mspID, "KA01")
+	l.mustInvoke(testDealer, nil, "CarContract:ProposeTransfer", "C1", dani.name, dani.mspID)
+	l.mustFail(testRTO, nil, "no accepted sale", "CarContract:RegisterCar", "C1", dani.name, dani.mspID, "KA01")
+	l.mustInvoke(dani, nil, "CarContract:AcceptTransfer", "C1")
+	l.mustFail(testRTO, nil, "has not agreed", "CarContract:RegisterCar", "C1", dani.name, dani.mspID, "KA01")
+
+	agreePrice(l, testDealer, "C1", "500000", "t1")
+	agreePrice(l, dani, "C1", "490000", "t1")
+	l.mustFail(testRTO, nil, "do not match", "CarContract:RegisterCar", "C1", dani.name, dani.mspID, "KA01")
+	agreePrice(l, dani, "C1", "500000", "t1")
+	l.mustFail(testRTO, nil, "no accepted sale to eve", "CarContract:RegisterCar", "C1", "eve", dani.mspID, "KA01")
+	l.mustInvoke(testRTO, nil, "CarContract:RegisterCar", "C1", dani.name, dani.mspID, "KA01")
+
+	car := l.readCar("C1")
+	if car.OwnedBy != dani.name || car.Status != StatusRegistered || car.PendingTransfer != nil {
+		t.Fatalf("car is %s owned by %s with transfer %v, want %s owned by %s with none", car.Status, car.OwnedBy, car.PendingTransfer, StatusRegistered, dani.name)
+	}
+	if n := saleAgreements(l); n != 0 {
+		t.Fatalf("%d sale agreements left, want none", n)
+	}
+	if strings.Contains(string(l.state[car.CarId]), "500000") {
+		t.Fatal("the sale price is in the world state")
+	}
+}
+
+func TestDealerSaleToDealer(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke(testAdmin, nil, "AccessContract:SetRoleMSPs", RoleDealer, `["Org2MSP","Org4MSP"]`)
+	city := testIdentity{"Org4MSP", RoleDealer, "city"}
+	addDealer(l, testDealer)
+	addDealer(l, city)
+	deliverCar(l, testDealer, "C1")
+	deliverCar(l, testDealer, "C2")
+
+	l.mustInvoke(testDealer, nil, "CarContract:ProposeTransfer", "C1", city.name, city.mspID)
+	l.mustInvoke(city, nil, "CarContract:AcceptTransfer", "C1")
+	l.mustFail(testRTO, nil, "has not agreed", "CarContract:ApproveTransfer", "C1")
+	agreePrice(l, testDealer, "C1", "450000", "t1")
+	agreePrice(l, city, "C1", "450000", "t1")
+	l.mustInvoke(testRTO, nil, "CarContract:ApproveTransfer", "C1")
+
+	car := l.readCar("C1")
+	if car.OwnedBy != city.name || car.OwnerMspId != city.mspID || car.Status != StatusAssignedToDealer {
+		t.Fatalf("car is %s owned by %s of %s, want %s owned by %s of %s", car.Status, car.OwnedBy, car.OwnerMspId, StatusAssignedToDealer, city.name, city.mspID)
+	}
+	if n := saleAgreements(l); n != 0 {
+		t.Fatalf("%d sale agreements left, want none", n)
+	}
+
+	// a customer is only given the car by registering it
+	dani := testOwner("dani")
+	l.mustInvoke(testDealer, nil, "CarContract:ProposeTransfer", "C2", dani.name, dani.mspID)
+	l.mustInvoke(dani, nil, "CarContract:AcceptTransfer", "C2")
+	agreePrice(l, testDealer, "C2", "500000", "t2")
+	agreePrice(l, dani, "C2", "500000", "t2")
+	l.mustFail(testRTO, nil, "register it to a buyer who is not a dealer", "CarContract:ApproveTransfer", "C2")
+	l.mustInvoke(testRTO, nil, "CarContract:RegisterCar", "C2", dani.name, dani.mspID, "KA02")
+}
@@ -0,0 +1,103 @@
+package contracts
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// ScrapRecord documents why and when a car was taken off the road
+type ScrapRecord struct {
+	Reason               string `json:"reason"`
+	CertificateReference string `json:"certificateReference"`
+	ScrappedBy           string `json:"scrappedBy"`
+	ScrappedAt           string `json:"scrappedAt"`
+	TxId                 string `json:"txId"`
+}
+
+// ScrapCar decommissions a car. The car stays in the world state with the
+// Scrapped status and the scrap record, so it can still be read, but it no
+// longer shows up in the default listings. The car can be scrapped by the
+// manufacturer while it is InFactory, by the dealer or owner holding it, or by
+// the RTO once it is registered. Its registration number is retired. A car
+// under an active lien cannot be scrapped; a pending lien is dropped.
+func (c *CarContract) ScrapCar(ctx contractapi.TransactionContextInterface, carID string, reason string, certificateReference string) (*Car, error) {
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer, RoleRTO, RoleOwner); err != nil {
+		return nil, err
+	}
+
+	reason = strings.TrimSpace(reason)
+	certificateReference = strings.TrimSpace(certificateReference)
+	if reason == "" || certificateReference == "" {
+		return nil, fmt.Errorf("a reason and a certificate reference are required to scrap car %s", carID)
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := canManageCar(ctx, car, caller)
+	if err != nil {
+		return nil, err
+	}
+	if !ok && car.Status != StatusScrapped {
+		return nil, fmt.Errorf("forbidden: car %s can only be scrapped by the manufacturer while it is %s, by the dealer or owner holding it, or by the RTO once it is %s", carID, StatusInFactory, StatusRegistered)
+	}
+	if car.PendingTransfer != nil {
+		return nil, fmt.Errorf("car %s has a pending transfer to %s, cancel it before scrapping the car", carID, car.PendingTransfer.Buyer)
+	}
+	if err := checkLien(car); err != nil {
+		return nil, err
+	}
+	if err := transitionCar(car, StatusScrapped); err != nil {
+		return nil, err
+	}
+	if car.PendingLien != nil {
+		car.PendingLien = nil
+		if err := putCarAnnotation(ctx, car, annotationLiens); err != nil {
+			return nil, err
+		}
+	}
+
+	scrappedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	car.Scrapping = &ScrapRecord{
+		Reason:               reason,
+		CertificateReference: certificateReference,
+		ScrappedBy:           caller.Name,
+		ScrappedAt:           scrappedAt,
+		TxId:                 ctx.GetStub().GetTxID(),
+	}
+	err = retirePlate(ctx, car, "scrapped: "+reason)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return nil, err
+	}
+	err = emitCarEvent(ctx, EventCarScrapped, car)
+	if err != nil {
+		return nil, err
+	}
+	return car, nil
+}
+
+// withoutScrapped drops the scrapped cars from a listing
+func withoutScrapped(cars []*Car) []*Car {
+	active := []*Car{}
+	for _, car := range cars {
+		if car.Status != StatusScrapped {
+			active = append(active, car)
+		}
+	}
+	return active
+}
@@ -0,0 +1,63 @@
+package contracts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CarStatus is the lifecycle state of a Car
+type CarStatus string
+
+// Lifecycle states of a Car
+const (
+	StatusInFactory        CarStatus = "InFactory"
+	StatusAssignedToDealer CarStatus = "AssignedToDealer"
+	StatusRegistered       CarStatus = "Registered"
+	StatusScrapped         CarStatus = "Scrapped"
+)
+
+// carStatusTransitions lists, for each state, the states a car may move to next
+var carStatusTransitions = map[CarStatus][]CarStatus{
+	StatusInFactory:        {StatusAssignedToDealer, StatusScrapped},
+	StatusAssignedToDealer: {StatusRegistered, StatusScrapped},
+	StatusRegistered:       {StatusScrapped},
+	StatusScrapped:         {},
+}
+
+// CanTransitionTo returns true when a car in status s may move to next
+func (s CarStatus) CanTransitionTo(next CarStatus) bool {
+	for _, allowed := range carStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionCar moves the car to the next status, rejecting moves that are not
+// in the transition table
+func transitionCar(car *Car, next CarStatus) error {
+	if !car.Status.CanTransitionTo(next) {
+		return fmt.Errorf("car %s cannot move from %s to %s", car.CarId, car.Status, next)
+	}
+	car.Status = next
+	return nil
+}
+
+// normalizeLegacyStatus converts the free-text statuses written by earlier
+// versions of this chaincode into a CarStatus, moving the registration
+// details that were embedded in the text into their own fields
+func normalizeLegacyStatus(car *Car) {
+	legacy := string(car.Status)
+	switch {
+	case legacy == "In Factory":
+		car.Status = StatusInFactory
+	case legacy == "assigned to a dealer":
+		car.Status = StatusAssignedToDealer
+	case strings.HasPrefix(legacy, "Registered to "):
+		car.Status = StatusRegistered
+		if i := strings.LastIndex(legacy, " with plate number "); i >= 0 && car.RegistrationNumber == "" {
+			car.RegistrationNumber = legacy[i+len(" with plate number "):]
+		}
+	}
+}
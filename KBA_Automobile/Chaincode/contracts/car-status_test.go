@@ -0,0 +1,57 @@
+package contracts
+
+import "testing"
+
+func TestCarStatusTransitions(t *testing.T) {
+	statuses := []CarStatus{StatusInFactory, StatusAssignedToDealer, StatusRegistered, StatusScrapped}
+	allowed := map[CarStatus]map[CarStatus]bool{
+		StatusInFactory:        {StatusAssignedToDealer: true, StatusScrapped: true},
+		StatusAssignedToDealer: {StatusRegistered: true, StatusScrapped: true},
+		StatusRegistered:       {StatusScrapped: true},
+		StatusScrapped:         {},
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[from][to]
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", from, to, got, want)
+			}
+
+			car := &Car{CarId: "C1", Status: from}
+			err := transitionCar(car, to)
+			if want && (err != nil || car.Status != to) {
+				t.Errorf("transitionCar from %s to %s = %v, status %s", from, to, err, car.Status)
+			}
+			if !want && (err == nil || car.Status != from) {
+				t.Errorf("transitionCar from %s to %s succeeded, status %s", from, to, car.Status)
+			}
+		}
+	}
+	if CarStatus("In Factory").CanTransitionTo(StatusAssignedToDealer) {
+		t.Errorf("an unknown status must not transition")
+	}
+}
+
+func TestNormalizeLegacyStatus(t *testing.T) {
+	tests := []struct {
+		status       string
+		registration string
+		want         CarStatus
+		plate        string
+	}{
+		{"In Factory", "", StatusInFactory, ""},
+		{"assigned to a dealer", "", StatusAssignedToDealer, ""},
+		{"Registered to Dani with plate number KL-01-CD-01", "", StatusRegistered, "KL-01-CD-01"},
+		{"Registered to Dani with plate number KL-01", "MH02", StatusRegistered, "MH02"},
+		{"Registered to Dani", "", StatusRegistered, ""},
+		{"Registered", "KA01", StatusRegistered, "KA01"},
+		{"Scrapped", "", StatusScrapped, ""},
+	}
+	for _, tt := range tests {
+		car := &Car{Status: CarStatus(tt.status), RegistrationNumber: tt.registration}
+		normalizeLegacyStatus(car)
+		if car.Status != tt.want || car.RegistrationNumber != tt.plate {
+			t.Errorf("normalizeLegacyStatus(%q) = %s, %q, want %s, %q", tt.status, car.Status, car.RegistrationNumber, tt.want, tt.plate)
+		}
+	}
+}
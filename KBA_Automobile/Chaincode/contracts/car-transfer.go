@@ -0,0 +1,293 @@
+package contracts
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// TransferStatus is the state of an owner-to-owner transfer
+type TransferStatus string
+
+// States of an owner-to-owner transfer. A transfer is proposed by the current
+// owner, accepted by the buyer and approved by the RTO, at which point the car
+// changes hands.
+const (
+	TransferProposed TransferStatus = "Proposed"
+	TransferAccepted TransferStatus = "Accepted"
+)
+
+// OwnershipTransfer is a sale of a car that is still in progress: a resale of a
+// registered car, or the sale of a car a dealer holds to a customer or to
+// another dealer
+type OwnershipTransfer struct {
+	Seller     string         `json:"seller"`
+	Buyer      string         `json:"buyer"`
+	Status     TransferStatus `json:"status"`
+	ProposedAt string         `json:"proposedAt"`
+	AcceptedAt string         `json:"acceptedAt,omitempty" metadata:",optional"`
+
+	// MSPs of the seller and the buyer. Enrollment IDs are only unique within
+	// an org, so the parties are the name together with the MSP.
+	SellerMspId string `json:"sellerMspId,omitempty" metadata:",optional"`
+	BuyerMspId  string `json:"buyerMspId,omitempty" metadata:",optional"`
+
+	// times the seller and the buyer agreed to the sale price in the implicit
+	// collection of their org
+	SellerAgreedAt string `json:"sellerAgreedAt,omitempty" metadata:",optional"`
+	BuyerAgreedAt  string `json:"buyerAgreedAt,omitempty" metadata:",optional"`
+}
+
+// OwnershipRecord is one link in the ownership chain of a car
+type OwnershipRecord struct {
+	Owner string `json:"owner"`
+	Since string `json:"since"`
+	TxId  string `json:"txId"`
+	MspId string `json:"mspId,omitempty" metadata:",optional"`
+}
+
+// changeOwner hands the car over to the new owner and appends the change to
+// its ownership chain. Every change of OwnedBy goes through here, so a car
+// under an active lien never changes hands. mspID is the org of the new owner;
+// from now on only its peers can endorse changes to the car.
+func changeOwner(ctx contractapi.TransactionContextInterface, car *Car, owner string, mspID string) error {
+	if err := checkLien(car); err != nil {
+		return err
+	}
+	since, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	err = setOwnerEndorsement(ctx, car.CarId, mspID)
+	if err != nil {
+		return err
+	}
+	car.OwnedBy = owner
+	car.OwnerMspId = mspID
+	car.OwnershipChain = append(car.OwnershipChain, &OwnershipRecord{
+		Owner: owner,
+		Since: since,
+		TxId:  ctx.GetStub().GetTxID(),
+		MspId: mspID,
+	})
+	return nil
+}
+
+// ProposeTransfer starts the sale of a car to the buyer, the identity with the
+// given enrollment ID in the org buyerMspID. Only the current owner of the car
+// may propose it: the owner of a registered car, or the dealer a car is
+// assigned to.
+func (c *CarContract) ProposeTransfer(ctx contractapi.TransactionContextInterface, carID string, buyer string, buyerMspID string) (string, error) {
+	if err := requireRole(ctx, RoleOwner, RoleDealer); err != nil {
+		return "", err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return "", err
+	}
+	seller, err := getSubmitter(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !isParty(seller, car.OwnedBy, car.OwnerMspId) {
+		return "", fmt.Errorf("forbidden: only the owner of car %s can propose its transfer", carID)
+	}
+	if car.Status != StatusRegistered && car.Status != StatusAssignedToDealer {
+		return "", fmt.Errorf("car %s must be %s or %s to be transferred, it is %s", carID, StatusRegistered, StatusAssignedToDealer, car.Status)
+	}
+	if car.PendingTransfer != nil {
+		return "", fmt.Errorf("car %s already has a pending transfer to %s", carID, car.PendingTransfer.Buyer)
+	}
+	if err := checkBlockingRecalls(car); err != nil {
+		return "", err
+	}
+	if err := checkLien(car); err != nil {
+		return "", err
+	}
+	buyerMspID = strings.TrimSpace(buyerMspID)
+	if buyer == "" || buyerMspID == "" {
+		return "", fmt.Errorf("the buyer and the MSP ID of the buyer's org are required")
+	}
+	if isParty(seller, buyer, buyerMspID) {
+		return "", fmt.Errorf("the buyer must be someone other than the current owner")
+	}
+
+	proposedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return "", err
+	}
+	car.PendingTransfer = &OwnershipTransfer{
+		Seller:      seller.Name,
+		Buyer:       buyer,
+		Status:      TransferProposed,
+		ProposedAt:  proposedAt,
+		SellerMspId: seller.MspId,
+		BuyerMspId:  buyerMspID,
+	}
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return "", err
+	}
+	err = emitCarEvent(ctx, EventTransferProposed, car)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Transfer of car %v to %v proposed", carID, buyer), nil
+}
+
+// AcceptTransfer records the buyer's consent to a proposed transfer
+func (c *CarContract) AcceptTransfer(ctx contractapi.TransactionContextInterface, carID string) (string, error) {
+	if err := requireRole(ctx, RoleOwner, RoleDealer); err != nil {
+		return "", err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return "", err
+	}
+	if car.PendingTransfer == nil || car.PendingTransfer.Status != TransferProposed {
+		return "", fmt.Errorf("car %s has no transfer awaiting acceptance", carID)
+	}
+	buyer, err := getSubmitter(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !isParty(buyer, car.PendingTransfer.Buyer, car.PendingTransfer.BuyerMspId) {
+		return "", fmt.Errorf("forbidden: only the buyer can accept the transfer of car %s", carID)
+	}
+
+	acceptedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return "", err
+	}
+	car.PendingTransfer.Status = TransferAccepted
+	car.PendingTransfer.AcceptedAt = acceptedAt
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return "", err
+	}
+	err = emitCarEvent(ctx, EventTransferAccepted, car)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Transfer of car %v accepted by %v", carID, buyer.Name), nil
+}
+
+// ApproveTransfer completes an accepted transfer, moving the car to the buyer.
+// The seller and the buyer must have agreed to the same sale price with
+// AgreeSalePrice; their agreements are removed once the car has moved. A car a
+// dealer holds can only be approved to another dealer, it goes to a customer
+// with RegisterCar.
+func (c *CarContract) ApproveTransfer(ctx contractapi.TransactionContextInterface, carID string) (string, error) {
+	if err := requireRole(ctx, RoleRTO); err != nil {
+		return "", err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return "", err
+	}
+	if car.PendingTransfer == nil || car.PendingTransfer.Status != TransferAccepted {
+		return "", fmt.Errorf("car %s has no accepted transfer awaiting approval", carID)
+	}
+	if car.Status == StatusAssignedToDealer {
+		dealer, err := getDealer(ctx, car.PendingTransfer.Buyer)
+		if err != nil {
+			return "", err
+		}
+		if dealer == nil || dealer.MspId != car.PendingTransfer.BuyerMspId {
+			return "", fmt.Errorf("car %s is held by a dealer, register it to a buyer who is not a dealer with RegisterCar", carID)
+		}
+	}
+	if err := checkBlockingRecalls(car); err != nil {
+		return "", err
+	}
+	if err := verifySaleAgreement(ctx, car); err != nil {
+		return "", err
+	}
+	err = deleteSaleAgreements(ctx, car)
+	if err != nil {
+		return "", err
+	}
+
+	buyer := car.PendingTransfer.Buyer
+	err = changeOwner(ctx, car, buyer, car.PendingTransfer.BuyerMspId)
+	if err != nil {
+		return "", err
+	}
+	car.PendingTransfer = nil
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return "", err
+	}
+	err = emitCarEvent(ctx, EventOwnershipTransferred, car)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Car %v transferred to %v", carID, buyer), nil
+}
+
+// CancelTransfer withdraws a pending transfer. The seller, the buyer and the
+// RTO may cancel it.
+func (c *CarContract) CancelTransfer(ctx contractapi.TransactionContextInterface, carID string) (string, error) {
+	if err := requireRole(ctx, RoleOwner, RoleDealer, RoleRTO); err != nil {
+		return "", err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return "", err
+	}
+	if car.PendingTransfer == nil {
+		return "", fmt.Errorf("car %s has no pending transfer", carID)
+	}
+
+	isRTO, err := hasRole(ctx, RoleRTO)
+	if err != nil {
+		return "", err
+	}
+	if !isRTO {
+		caller, err := getSubmitter(ctx)
+		if err != nil {
+			return "", err
+		}
+		transfer := car.PendingTransfer
+		if !isParty(caller, transfer.Seller, transfer.SellerMspId) && !isParty(caller, transfer.Buyer, transfer.BuyerMspId) {
+			return "", fmt.Errorf("forbidden: only the seller, the buyer or the RTO can cancel the transfer of car %s", carID)
+		}
+	}
+
+	err = deleteSaleAgreements(ctx, car)
+	if err != nil {
+		return "", err
+	}
+	car.PendingTransfer = nil
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return "", err
+	}
+	err = emitCarEvent(ctx, EventTransferCancelled, car)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Transfer of car %v cancelled", carID), nil
+}
+
+// GetOwnershipChain returns every owner the car has had, oldest first
+func (c *CarContract) GetOwnershipChain(ctx contractapi.TransactionContextInterface, carID string) ([]*OwnershipRecord, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+	return car.OwnershipChain, nil
+}
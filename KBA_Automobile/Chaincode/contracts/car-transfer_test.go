@@ -0,0 +1,58 @@
+package contracts
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOwnershipTransfer(t *testing.T) {
+	l := newTestLedger(t)
+	addDealer(l, testDealer)
+	deliverCar(l, testDealer, "C1")
+	dani, eve, finn := testOwner("dani"), testOwner("eve"), testOwner("finn")
+	l.mustFail(testRTO, nil, "MSP ID of the owner's org are required", "CarContract:RegisterCar", "C1", dani.name, "", "KA01")
+	sellCar(l, testDealer, dani, "C1", "KA01")
+
+	// an owner of another org with the same name is not the owner
+	l.mustInvoke(testAdmin, nil, "AccessContract:SetRoleMSPs", RoleOwner, `["Org3MSP","Org4MSP"]`)
+	impostor := testIdentity{"Org4MSP", RoleOwner, dani.name}
+	l.mustFail(impostor, nil, "forbidden: only the owner of car C1", "CarContract:ProposeTransfer", "C1", finn.name, finn.mspID)
+	l.mustFail(eve, nil, "forbidden: only the owner of car C1", "CarContract:ProposeTransfer", "C1", finn.name, finn.mspID)
+	l.mustFail(dani, nil, "someone other than the current owner", "CarContract:ProposeTransfer", "C1", dani.name, dani.mspID)
+
+	// a buyer of the same name in another org is a different buyer
+	l.mustInvoke(dani, nil, "CarContract:ProposeTransfer", "C1", dani.name, "Org4MSP")
+	l.mustFail(dani, nil, "already has a pending transfer", "CarContract:ProposeTransfer", "C1", finn.name, finn.mspID)
+	l.mustFail(dani, nil, "forbidden: only the buyer", "CarContract:AcceptTransfer", "C1")
+	l.mustFail(testRTO, nil, "no accepted transfer", "CarContract:ApproveTransfer", "C1")
+	l.mustInvoke(impostor, nil, "CarContract:AcceptTransfer", "C1")
+	l.mustFail(eve, nil, "forbidden: only the seller, the buyer or the RTO", "CarContract:CancelTransfer", "C1")
+	l.mustInvoke(impostor, nil, "CarContract:CancelTransfer", "C1")
+	if l.lastEvent() != EventTransferCancelled || l.readCar("C1").PendingTransfer != nil {
+		t.Fatalf("event = %s, want %s and no pending transfer", l.lastEvent(), EventTransferCancelled)
+	}
+
+	sellCar(l, dani, finn, "C1", "")
+	if l.lastEvent() != EventOwnershipTransferred {
+		t.Fatalf("event = %s, want %s", l.lastEvent(), EventOwnershipTransferred)
+	}
+	car := l.readCar("C1")
+	if car.OwnedBy != finn.name || car.OwnerMspId != finn.mspID || car.PendingTransfer != nil || car.RegistrationNumber != "KA01" {
+		t.Fatalf("car is owned by %s of %s with transfer %v and plate %s, want %s of %s with none and KA01", car.OwnedBy, car.OwnerMspId, car.PendingTransfer, car.RegistrationNumber, finn.name, finn.mspID)
+	}
+
+	var chain []*OwnershipRecord
+	if err := json.Unmarshal([]byte(l.mustInvoke(eve, nil, "CarContract:GetOwnershipChain", "C1")), &chain); err != nil {
+		t.Fatal(err)
+	}
+	owners := []string{"fac", testDealer.name, dani.name, finn.name}
+	if len(chain) != len(owners) {
+		t.Fatalf("ownership chain has %d owners, want %v", len(chain), owners)
+	}
+	for i, record := range chain {
+		if record.Owner != owners[i] {
+			t.Fatalf("owner %d = %s, want %s", i, record.Owner, owners[i])
+		}
+	}
+	l.mustFail(dani, nil, "forbidden: only the owner of car C1", "CarContract:ProposeTransfer", "C1", eve.name, eve.mspID)
+}
@@ -0,0 +1,168 @@
+package contracts
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// CarPatch is a partial update of a car. Fields left empty are not changed.
+type CarPatch struct {
+	Make              string `json:"make,omitempty" metadata:",optional"`
+	Model             string `json:"model,omitempty" metadata:",optional"`
+	Color             string `json:"color,omitempty" metadata:",optional"`
+	DateOfManufacture string `json:"dateOfManufacture,omitempty" metadata:",optional"`
+}
+
+// FieldChange is the old and new value of one changed field of a car
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// CarUpdate is the audit record of one UpdateCar transaction
+type CarUpdate struct {
+	Reason    string         `json:"reason"`
+	UpdatedBy string         `json:"updatedBy"`
+	UpdatedAt string         `json:"updatedAt"`
+	TxId      string         `json:"txId"`
+	Changes   []*FieldChange `json:"changes"`
+}
+
+// UpdateCar applies a partial patch to a car. The manufacturer may correct the
+// make, model and date of manufacture only while the car is InFactory. The
+// color may be changed later on to record a repaint: by the manufacturer in the
+// factory, by the dealer or owner holding the car, or by the RTO once the car
+// is registered. Every update is recorded on the car with its reason. A
+// corrected car is added to the recalls that now cover it and removed from the
+// open recalls that no longer do.
+func (c *CarContract) UpdateCar(ctx contractapi.TransactionContextInterface, carID string, patch *CarPatch, reason string) (*Car, error) {
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer, RoleRTO, RoleOwner); err != nil {
+		return nil, err
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("a reason is required to update car %s", carID)
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+	if car.Status == StatusScrapped {
+		return nil, fmt.Errorf("car %s is %s and can no longer be updated", carID, car.Status)
+	}
+
+	caller, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*FieldChange
+	recallsChanged := false
+	setField := func(field string, current *string, value string) {
+		if value != "" && value != *current {
+			changes = append(changes, &FieldChange{Field: field, From: *current, To: value})
+			*current = value
+		}
+	}
+
+	if patch.Make != "" || patch.Model != "" || patch.DateOfManufacture != "" {
+		if err := canCorrectCar(ctx, car); err != nil {
+			return nil, err
+		}
+
+		setField("make", &car.Make, patch.Make)
+		setField("model", &car.Model, patch.Model)
+
+		if patch.DateOfManufacture != "" {
+			txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the transaction timestamp: %v", err)
+			}
+			manufactured, err := parseManufactureDate(patch.DateOfManufacture, txTimestamp.AsTime())
+			if err != nil {
+				return nil, err
+			}
+			setField("dateOfManufacture", &car.DateOfManufacture, manufactured.Format("2006-01-02"))
+			if car.Vin != "" {
+				car.ManufacturerCode, car.ModelYear = decodeVIN(car.Vin, manufactured)
+			}
+		}
+
+		// the corrected car may fall into other recall campaigns
+		if len(changes) > 0 {
+			recallsChanged, err = refreshRecalls(ctx, car)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if patch.Color != "" {
+		if err := canRepaintCar(ctx, car, caller); err != nil {
+			return nil, err
+		}
+		setField("color", &car.Color, patch.Color)
+	}
+
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("the patch does not change car %s", carID)
+	}
+
+	updatedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	car.Updates = append(car.Updates, &CarUpdate{
+		Reason:    reason,
+		UpdatedBy: caller.Name,
+		UpdatedAt: updatedAt,
+		TxId:      ctx.GetStub().GetTxID(),
+		Changes:   changes,
+	})
+
+	err = putCar(ctx, car)
+	if err != nil {
+		return nil, err
+	}
+	if recallsChanged {
+		err = putCarAnnotation(ctx, car, annotationRecalls)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = emitCarEvent(ctx, EventCarUpdated, car)
+	if err != nil {
+		return nil, err
+	}
+	return car, nil
+}
+
+// canCorrectCar checks that the caller may correct the make, model or date of
+// manufacture of the car
+func canCorrectCar(ctx contractapi.TransactionContextInterface, car *Car) error {
+	isManufacturer, err := hasRole(ctx, RoleManufacturer)
+	if err != nil {
+		return err
+	}
+	if !isManufacturer || car.Status != StatusInFactory {
+		return fmt.Errorf("forbidden: the make, model and date of manufacture of car %s can only be corrected by the manufacturer while it is %s", car.CarId, StatusInFactory)
+	}
+	return nil
+}
+
+// canRepaintCar checks that the caller may change the color of the car
+func canRepaintCar(ctx contractapi.TransactionContextInterface, car *Car, caller *Submitter) error {
+	ok, err := canManageCar(ctx, car, caller)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("forbidden: the color of car %s can only be changed by the manufacturer while it is %s, by the dealer or owner holding it, or by the RTO once it is %s", car.CarId, StatusInFactory, StatusRegistered)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Names of the chaincode events emitted by the automobile chaincode. Fabric
+// keeps a single event per transaction, so each transaction emits exactly one.
+const (
+	EventCarCreated      = "CarCreated"
+	EventCarsCreated     = "CarsCreated"
+	EventCarUpdated      = "CarUpdated"
+	EventCarScrapped     = "CarScrapped"
+	EventCarDeleted      = "CarDeleted"
+	EventCarRegistered   = "CarRegistered"
+	EventCarReRegistered = "CarReRegistered"
+
+	EventTransferProposed     = "TransferProposed"
+	EventTransferAccepted     = "TransferAccepted"
+	EventTransferCancelled    = "TransferCancelled"
+	EventOwnershipTransferred = "OwnershipTransferred"
+	EventSalePriceAgreed      = "SalePriceAgreed"
+
+	EventOdometerRecorded          = "OdometerRecorded"
+	EventOdometerRollbackSuspected = "OdometerRollbackSuspected"
+	EventServiceRecorded           = "ServiceRecorded"
+
+	EventLienRequested = "LienRequested"
+	EventLienPlaced    = "LienPlaced"
+	EventLienDeclined  = "LienDeclined"
+	EventLienReleased  = "LienReleased"
+
+	EventRecallIssued     = "RecallIssued"
+	EventRecallRemediated = "RecallRemediated"
+
+	EventOrderCreated   = "OrderCreated"
+	EventOrderDeleted   = "OrderDeleted"
+	EventOrderMatched   = "OrderMatched"
+	EventOrderDelivered = "OrderDelivered"
+	EventOrderCancelled = "OrderCancelled"
+	EventOrderPurged    = "OrderPurged"
+
+	EventPrivateDataPurgeSet = "PrivateDataPurgeSet"
+
+	EventDealerRegistered = "DealerRegistered"
+	EventDealerActivated  = "DealerActivated"
+
+	EventRoleMSPsSet = "RoleMSPsSet"
+)
+
+// EventVersion is the version of the event payloads below. It is increased
+// whenever a payload changes in a way that is not backwards compatible.
+const EventVersion = 1
+
+// EventHeader is embedded in every event payload
+type EventHeader struct {
+	Version   int    `json:"version"`
+	TxId      string `json:"txId"`
+	Timestamp string `json:"timestamp"`
+}
+
+// CarEvent is the payload of the car events. Cars live in the public world
+// state, so the whole record is included.
+type CarEvent struct {
+	EventHeader
+	Car *Car `json:"car"`
+}
+
+// CarsBatchEvent is the payload of the CarsCreated event. Batches can be
+// large, so only the IDs of the new cars are included.
+type CarsBatchEvent struct {
+	EventHeader
+	CarIds []string `json:"carIds"`
+}
+
+// OrderEvent is the payload of the order events. Orders are private data, so
+// only the order ID is published, together with the car it was matched to.
+type OrderEvent struct {
+	EventHeader
+	OrderId string `json:"orderId"`
+	CarId   string `json:"carId,omitempty"`
+}
+
+// DealerEvent is the payload of the dealer events. The dealer
+// registry is public, so the whole record is included.
+type DealerEvent struct {
+	EventHeader
+	Dealer *Dealer `json:"dealer"`
+}
+
+// PurgeSettingEvent is the payload of the PrivateDataPurgeSet event
+type PurgeSettingEvent struct {
+	EventHeader
+	Setting *PurgeSetting `json:"setting"`
+}
+
+// RoleMSPsEvent is the payload of the RoleMSPsSet event
+type RoleMSPsEvent struct {
+	EventHeader
+	RoleMSPs *RoleMSPs `json:"roleMsps"`
+}
+
+// newEventHeader returns the header for an event raised by the current transaction
+func newEventHeader(ctx contractapi.TransactionContextInterface) (EventHeader, error) {
+	timestamp, err := getTxTimestamp(ctx)
+	if err != nil {
+		return EventHeader{}, err
+	}
+	return EventHeader{
+		Version:   EventVersion,
+		TxId:      ctx.GetStub().GetTxID(),
+		Timestamp: timestamp,
+	}, nil
+}
+
+// emitCarEvent sets a CarEvent with the given name on the transaction
+func emitCarEvent(ctx contractapi.TransactionContextInterface, name string, car *Car) error {
+	header, err := newEventHeader(ctx)
+	if err != nil {
+		return err
+	}
+	return setEvent(ctx, name, CarEvent{EventHeader: header, Car: car})
+}
+
+// emitOrderEvent sets an OrderEvent with the given name on the transaction
+func emitOrderEvent(ctx contractapi.TransactionContextInterface, name string, orderID string, carID string) error {
+	header, err := newEventHeader(ctx)
+	if err != nil {
+		return err
+	}
+	return setEvent(ctx, name, OrderEvent{EventHeader: header, OrderId: orderID, CarId: carID})
+}
+
+// emitDealerEvent sets a DealerEvent with the given name on the transaction
+func emitDealerEvent(ctx contractapi.TransactionContextInterface, name string, dealer *Dealer) error {
+	header, err := newEventHeader(ctx)
+	if err != nil {
+		return err
+	}
+	return setEvent(ctx, name, DealerEvent{EventHeader: header, Dealer: dealer})
+}
+
+// emitPurgeSettingEvent sets a PurgeSettingEvent with the given name on the transaction
+func emitPurgeSettingEvent(ctx contractapi.TransactionContextInterface, name string, setting *PurgeSetting) error {
+	header, err := newEventHeader(ctx)
+	if err != nil {
+		return err
+	}
+	return setEvent(ctx, name, PurgeSettingEvent{EventHeader: header, Setting: setting})
+}
+
+// emitRoleMSPsEvent sets a RoleMSPsEvent with the given name on the transaction
+func emitRoleMSPsEvent(ctx contractapi.TransactionContextInterface, name string, trusted *RoleMSPs) error {
+	header, err := newEventHeader(ctx)
+	if err != nil {
+		return err
+	}
+	return setEvent(ctx, name, RoleMSPsEvent{EventHeader: header, RoleMSPs: trusted})
+}
+
+func setEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v", name, err)
+	}
+	return ctx.GetStub().SetEvent(name, bytes)
+}
@@ -0,0 +1,122 @@
+package contracts
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// IndexRebuildResult is one page of a rebuild of the composite-key indexes.
+// Pass the bookmark back in to rebuild the next page; it is empty once every
+// record has been indexed.
+type IndexRebuildResult struct {
+	Indexed  int32  `json:"indexed"`
+	Bookmark string `json:"bookmark"`
+	// PlateConflicts are the cars whose registration number another car
+	// already holds in the plate index. Their plate is left out of the index
+	// until the RTO re-registers one of them.
+	PlateConflicts []string `json:"plateConflicts,omitempty" metadata:",optional"`
+}
+
+// RebuildIndexes writes the composite index keys of one page of cars, taken
+// in car ID order. Cars written before the indexes existed have none, so they
+// are missing from GetAllCars, GetCarsByOwner, GetCarsByAttributes,
+// GetCarsByStatus and IssueRecall, and their plates, which legacy cars only
+// hold in their status, are not checked for uniqueness. Run it page by page
+// until the bookmark comes back empty after upgrading from such a version. It
+// is reserved to the admin role and only writes index keys, so it needs no
+// endorsement of the owning orgs.
+func (c *CarContract) RebuildIndexes(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*IndexRebuildResult, error) {
+	if err := requireRole(ctx, RoleAdmin); err != nil {
+		return nil, err
+	}
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	stub := ctx.GetStub()
+	resultsIterator, responseMetadata, err := stub.GetStateByRangeWithPagination("", "", pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	cars, err := carResultIteratorFunction(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &IndexRebuildResult{Bookmark: responseMetadata.Bookmark}
+	// plates indexed in this page, which the plate index does not show yet
+	plates := make(map[string]string)
+	for _, car := range cars {
+		if car.AssetType != "car" {
+			continue
+		}
+		keys, err := carIndexKeys(ctx, car)
+		if err != nil {
+			return nil, err
+		}
+		if car.RegistrationNumber != "" {
+			plate := normalizePlate(car.RegistrationNumber)
+			holder, err := getCarIDByPlate(ctx, plate)
+			if err != nil {
+				return nil, err
+			}
+			if holder == "" {
+				holder = plates[plate]
+			}
+			if holder != "" && holder != car.CarId {
+				result.PlateConflicts = append(result.PlateConflicts, car.CarId)
+				// the plate key is the last one
+				keys = keys[:len(keys)-1]
+			} else {
+				plates[plate] = car.CarId
+			}
+		}
+		for _, key := range keys {
+			if err := stub.PutState(key, indexValue); err != nil {
+				return nil, err
+			}
+		}
+		result.Indexed++
+	}
+	return result, nil
+}
+
+// RebuildIndexes writes the composite index keys of one page of orders of
+// every dealer, taken in order ID order. Orders written before the indexes
+// existed have none, so GetMatchingOrders, AutoMatch and the status queries
+// miss them. It works like CarContract:RebuildIndexes and is reserved to the
+// admin role.
+func (o *OrderContract) RebuildIndexes(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*IndexRebuildResult, error) {
+	if err := requireRole(ctx, RoleAdmin); err != nil {
+		return nil, err
+	}
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	scope, err := allOrdersScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	page, err := getScopedOrdersPage(ctx, scope, "", "", pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &IndexRebuildResult{Bookmark: page.Bookmark}
+	for _, order := range page.Records {
+		keys, err := orderIndexKeys(ctx, order)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if err := ctx.GetStub().PutPrivateData(orderCollection(order), key, indexValue); err != nil {
+				return nil, fmt.Errorf("failed to index order %s: %v", order.OrderID, err)
+			}
+		}
+		result.Indexed++
+	}
+	return result, nil
+}
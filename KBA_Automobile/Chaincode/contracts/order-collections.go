@@ -0,0 +1,424 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// OrderCollection is the private data collection every order was kept in
+// before each dealer got a collection of its own. Fabric cannot remove a
+// collection from a chaincode definition, so it stays in the collections
+// config and the orders placed before are still read and updated there.
+const OrderCollection = "OrderCollection"
+
+// dealerIndex is the composite key object type of the dealer registry in the
+// world state
+const dealerIndex = "dealer~name"
+
+// dealerNamePattern is the form of a dealer name. Collection names are built
+// from it, so it is limited to the characters Fabric allows in them:
+// letters and digits, with single '_' or '-' separators between them.
+var dealerNamePattern = regexp.MustCompile(`^[A-Za-z0-9]+([-_][A-Za-z0-9]+)*$`)
+
+// maxDealerNameLength is the longest dealer name
+const maxDealerNameLength = 64
+
+// Statuses of a dealer. A dealer is Pending from its registration until its
+// collection is deployed and the manufacturer activates it.
+const (
+	DealerPending = "Pending"
+	DealerActive  = "Active"
+)
+
+// Dealer is a dealer the manufacturer takes orders from. The name is the
+// enrollment ID of the dealer's identity. Its orders are kept in a collection
+// shared by the manufacturer's org and the dealer's org only, so every dealer
+// has an org of its own.
+type Dealer struct {
+	AssetType    string     `json:"assetType"`
+	Name         string     `json:"name"`
+	MspId        string     `json:"mspId"`
+	Collection   string     `json:"collection"`
+	Status       string     `json:"status"`
+	RegisteredBy *Submitter `json:"registeredBy"`
+	RegisteredAt string     `json:"registeredAt"`
+	ActivatedBy  *Submitter `json:"activatedBy,omitempty" metadata:",optional"`
+	ActivatedAt  string     `json:"activatedAt,omitempty" metadata:",optional"`
+}
+
+// isActive returns true once the dealer's collection is deployed
+func (d *Dealer) isActive() bool {
+	return d.Status == DealerActive
+}
+
+// normalizeLegacyDealer sets the status of dealers registered before dealers
+// had one. They are pending, as nothing tells whether their collection is
+// deployed.
+func normalizeLegacyDealer(dealer *Dealer) {
+	if dealer.Status == "" {
+		dealer.Status = DealerPending
+	}
+}
+
+// DealerCollection returns the name of the private data collection holding the
+// orders of a dealer
+func DealerCollection(dealerName string) string {
+	return OrderCollection + "-" + dealerName
+}
+
+// RegisterDealer records a dealer of the given org as Pending. Its collection
+// must then be added to the collections config of the chaincode definition,
+// see tools/gencollections, and the dealer activated with ActivateDealer
+// before it can order.
+func (o *OrderContract) RegisterDealer(ctx contractapi.TransactionContextInterface, name string, mspID string) (*Dealer, error) {
+	if err := requireRole(ctx, RoleManufacturer); err != nil {
+		return nil, err
+	}
+
+	if len(name) > maxDealerNameLength || !dealerNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("a dealer name is up to %d letters and digits, separated by single '_' or '-', got %q", maxDealerNameLength, name)
+	}
+	mspID = strings.TrimSpace(mspID)
+	if mspID == "" {
+		return nil, fmt.Errorf("the MSP ID of dealer %s is required", name)
+	}
+	existing, err := getDealer(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("dealer %s is already registered", name)
+	}
+	if err := checkDealerMSP(ctx, mspID); err != nil {
+		return nil, err
+	}
+
+	registeredBy, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	registeredAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dealer := &Dealer{
+		AssetType:    "dealer",
+		Name:         name,
+		MspId:        mspID,
+		Collection:   DealerCollection(name),
+		Status:       DealerPending,
+		RegisteredBy: registeredBy,
+		RegisteredAt: registeredAt,
+	}
+
+	err = putDealer(ctx, dealer)
+	if err != nil {
+		return nil, err
+	}
+	err = emitDealerEvent(ctx, EventDealerRegistered, dealer)
+	if err != nil {
+		return nil, err
+	}
+	return dealer, nil
+}
+
+// ActivateDealer lets a pending dealer order once the chaincode definition
+// holding its collection is committed. It fails while the peer does not know
+// the collection, so the orders of the dealer are never read from or written
+// to a collection that is not deployed.
+func (o *OrderContract) ActivateDealer(ctx contractapi.TransactionContextInterface, name string) (*Dealer, error) {
+	if err := requireRole(ctx, RoleManufacturer); err != nil {
+		return nil, err
+	}
+
+	dealer, err := getDealer(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if dealer == nil {
+		return nil, fmt.Errorf("dealer %s is not registered", name)
+	}
+	if dealer.isActive() {
+		return nil, fmt.Errorf("dealer %s is already %s", name, DealerActive)
+	}
+	// any key will do: reading a hash from a collection the chaincode
+	// definition does not hold fails
+	_, err = ctx.GetStub().GetPrivateDataHash(dealer.Collection, name)
+	if err != nil {
+		return nil, fmt.Errorf("collection %s of dealer %s is not deployed, commit a chaincode definition holding it first: %v", dealer.Collection, name, err)
+	}
+
+	activatedBy, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	activatedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dealer.Status = DealerActive
+	dealer.ActivatedBy = activatedBy
+	dealer.ActivatedAt = activatedAt
+
+	err = putDealer(ctx, dealer)
+	if err != nil {
+		return nil, err
+	}
+	err = emitDealerEvent(ctx, EventDealerActivated, dealer)
+	if err != nil {
+		return nil, err
+	}
+	return dealer, nil
+}
+
+// checkDealerMSP fails when the org cannot host a new dealer. Every peer of an
+// org holds the collections of the dealers of that org, so a dealer sharing
+// its org with the manufacturer or with another dealer would have its orders
+// on their peers.
+func checkDealerMSP(ctx contractapi.TransactionContextInterface, mspID string) error {
+	manufacturers, err := getRoleMSPs(ctx, RoleManufacturer)
+	if err != nil {
+		return err
+	}
+	for _, manufacturerMSP := range manufacturers.MspIds {
+		if manufacturerMSP == mspID {
+			return fmt.Errorf("%s is an org of the manufacturer, a dealer needs an org of its own", mspID)
+		}
+	}
+	dealers, err := getDealers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, dealer := range dealers {
+		if dealer.MspId == mspID {
+			return fmt.Errorf("%s is already the org of dealer %s, a dealer needs an org of its own", mspID, dealer.Name)
+		}
+	}
+	return nil
+}
+
+// putDealer writes the dealer to the registry
+func putDealer(ctx contractapi.TransactionContextInterface, dealer *Dealer) error {
+	key, err := ctx.GetStub().CreateCompositeKey(dealerIndex, []string{dealer.Name})
+	if err != nil {
+		return err
+	}
+	value, _ := json.Marshal(dealer)
+	err = ctx.GetStub().PutState(key, value)
+	if err != nil {
+		return fmt.Errorf("failed to put to world state. %v", err)
+	}
+	return nil
+}
+
+// GetDealers returns the registered dealers, pending and active. The result is
+// the dealer list tools/gencollections generates the collections config from.
+func (o *OrderContract) GetDealers(ctx contractapi.TransactionContextInterface) ([]*Dealer, error) {
+	if err := requireRole(ctx, RoleManufacturer); err != nil {
+		return nil, err
+	}
+	return getDealers(ctx)
+}
+
+// getDealer returns the registered dealer with the given name, or nil
+func getDealer(ctx contractapi.TransactionContextInterface, name string) (*Dealer, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(dealerIndex, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	value, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	var dealer Dealer
+	err = json.Unmarshal(value, &dealer)
+	if err != nil {
+		return nil, err
+	}
+	normalizeLegacyDealer(&dealer)
+	return &dealer, nil
+}
+
+// getDealers returns all the registered dealers, ordered by name
+func getDealers(ctx contractapi.TransactionContextInterface) ([]*Dealer, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(dealerIndex, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	dealers := []*Dealer{}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var dealer Dealer
+		err = json.Unmarshal(queryResult.Value, &dealer)
+		if err != nil {
+			return nil, err
+		}
+		normalizeLegacyDealer(&dealer)
+		dealers = append(dealers, &dealer)
+	}
+	return dealers, nil
+}
+
+// getCallerDealer returns the registered dealer the caller is, or nil. The
+// dealer must have been registered with the caller's org, so that an identity
+// of another org with the same enrollment ID is not taken for it.
+func getCallerDealer(ctx contractapi.TransactionContextInterface) (*Dealer, error) {
+	submitter, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dealer, err := getDealer(ctx, submitter.Name)
+	if err != nil {
+		return nil, err
+	}
+	if dealer == nil || dealer.MspId != submitter.MspId {
+		return nil, nil
+	}
+	return dealer, nil
+}
+
+// orderScope is the set of orders a caller may see: the collections to look
+// in and, for a dealer, its name, as a dealer only sees the orders it placed
+// itself in the shared OrderCollection
+type orderScope struct {
+	collections []string
+	dealer      string
+}
+
+// getOrderScope returns the order scope of the caller. The manufacturer sees
+// the collections of all the active dealers, an active dealer its own.
+func getOrderScope(ctx contractapi.TransactionContextInterface) (*orderScope, error) {
+	isManufacturer, err := hasRole(ctx, RoleManufacturer)
+	if err != nil {
+		return nil, err
+	}
+	if isManufacturer {
+		return allOrdersScope(ctx)
+	}
+
+	submitter, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scope := &orderScope{collections: []string{OrderCollection}, dealer: submitter.Name}
+	dealer, err := getCallerDealer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if dealer != nil && dealer.isActive() {
+		scope.collections = append(scope.collections, dealer.Collection)
+	}
+	return scope, nil
+}
+
+// allOrdersScope returns the scope holding the orders of every dealer. The
+// collections of pending dealers may not be deployed yet and hold no orders,
+// so they are left out.
+func allOrdersScope(ctx contractapi.TransactionContextInterface) (*orderScope, error) {
+	dealers, err := getDealers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scope := &orderScope{collections: []string{OrderCollection}}
+	for _, dealer := range dealers {
+		if dealer.isActive() {
+			scope.collections = append(scope.collections, dealer.Collection)
+		}
+	}
+	return scope, nil
+}
+
+// allows returns true when the order is in the scope
+func (s *orderScope) allows(order *Order) bool {
+	return s.dealer == "" || order.DealerName == s.dealer
+}
+
+// filter returns the orders that are in the scope
+func (s *orderScope) filter(orders []*Order) []*Order {
+	var allowed []*Order
+	for _, order := range orders {
+		if s.allows(order) {
+			allowed = append(allowed, order)
+		}
+	}
+	return allowed
+}
+
+// getOrder returns the order from the first collection of the scope holding
+// it, or nil when the scope has no such order
+func (s *orderScope) getOrder(ctx contractapi.TransactionContextInterface, orderID string) (*Order, error) {
+	for _, collection := range s.collections {
+		order, err := getOrderFrom(ctx, collection, orderID)
+		if err != nil {
+			return nil, err
+		}
+		if order != nil && s.allows(order) {
+			return order, nil
+		}
+	}
+	return nil, nil
+}
+
+// holds returns true when any collection of the scope holds the key. Only the
+// hashes are read, so this works on peers of orgs that are not members of the
+// collections.
+func (s *orderScope) holds(ctx contractapi.TransactionContextInterface, key string) (bool, error) {
+	for _, collection := range s.collections {
+		hash, err := ctx.GetStub().GetPrivateDataHash(collection, key)
+		if err != nil {
+			return false, err
+		}
+		if hash != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getOrderFrom reads an order from the given collection, or returns nil when
+// the collection does not hold it
+func getOrderFrom(ctx contractapi.TransactionContextInterface, collection string, orderID string) (*Order, error) {
+	bytes, err := ctx.GetStub().GetPrivateData(collection, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if bytes == nil {
+		return nil, nil
+	}
+	order := new(Order)
+	err = json.Unmarshal(bytes, order)
+	if err != nil {
+		return nil, fmt.Errorf("Could not unmarshal private data collection data to type Order")
+	}
+	normalizeLegacyOrder(order)
+	return order, nil
+}
+
+// sortOrdersByID sorts orders gathered from several collections by order ID,
+// the order a range query over a single collection returns them in
+func sortOrdersByID(orders []*Order) {
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].OrderID < orders[j].OrderID
+	})
+}
+
+// orderCollection returns the collection the order is kept in
+func orderCollection(order *Order) string {
+	if order.Collection == "" {
+		return OrderCollection
+	}
+	return order.Collection
+}
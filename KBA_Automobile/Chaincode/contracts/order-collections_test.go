@@ -0,0 +1,100 @@
+package contracts
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// addDealer registers the dealer and activates it
+func addDealer(l *testLedger, dealer testIdentity) {
+	l.t.Helper()
+	l.mustInvoke(testManufacturer, nil, "OrderContract:RegisterDealer", dealer.name, dealer.mspID)
+	l.mustInvoke(testManufacturer, nil, "OrderContract:ActivateDealer", dealer.name)
+}
+
+func TestRegisterDealer(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke(testAdmin, nil, "AccessContract:SetRoleMSPs", RoleDealer, `["Org2MSP","Org4MSP"]`)
+	addDealer(l, testDealer)
+
+	tests := []struct {
+		name  string
+		mspID string
+		err   string
+	}{
+		{"trailing-", "Org4MSP", "a dealer name is"},
+		{"double__separator", "Org4MSP", "a dealer name is"},
+		{"-leading", "Org4MSP", "a dealer name is"},
+		{strings.Repeat("a", maxDealerNameLength+1), "Org4MSP", "a dealer name is"},
+		{testDealer.name, "Org4MSP", "already registered"},
+		{"second", testDealer.mspID, "already the org of dealer popular"},
+		{"inhouse", testManufacturer.mspID, "an org of the manufacturer"},
+	}
+	for _, tt := range tests {
+		l.mustFail(testManufacturer, nil, tt.err, "OrderContract:RegisterDealer", tt.name, tt.mspID)
+	}
+	l.mustFail(testDealer, nil, "forbidden", "OrderContract:RegisterDealer", "self", "Org4MSP")
+	l.mustInvoke(testManufacturer, nil, "OrderContract:RegisterDealer", "city_motors-2", "Org4MSP")
+}
+
+func TestPendingDealer(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke(testAdmin, nil, "AccessContract:SetRoleMSPs", RoleDealer, `["Org2MSP","Org4MSP"]`)
+	addDealer(l, testDealer)
+	placeOrder(l, testDealer, "O1", "Maruti", "Alto", "Red")
+
+	pending := testIdentity{"Org4MSP", RoleDealer, "city"}
+	l.mustInvoke(testManufacturer, nil, "OrderContract:RegisterDealer", pending.name, pending.mspID)
+	l.undeployed[DealerCollection(pending.name)] = true
+
+	transient := map[string][]byte{"make": []byte("Maruti"), "model": []byte("Alto"), "color": []byte("Red")}
+	l.mustFail(pending, transient, "is Pending", "OrderContract:CreateOrder", "O2")
+	// the undeployed collection is left out of the order queries
+	var orders []*Order
+	if err := json.Unmarshal([]byte(l.mustInvoke(testManufacturer, nil, "OrderContract:GetAllOrders")), &orders); err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].OrderID != "O1" {
+		t.Fatalf("GetAllOrders = %v, want O1 only", orders)
+	}
+	l.mustInvoke(testManufacturer, nil, "CarContract:CreateCar", "C1", "Maruti", "Alto", "Red", "Factory-1", "2024-05-01")
+	l.mustInvoke(testManufacturer, nil, "CarContract:GetMatchingOrders", "C1")
+	l.mustFail(testManufacturer, nil, "is not deployed", "OrderContract:ActivateDealer", pending.name)
+
+	delete(l.undeployed, DealerCollection(pending.name))
+	l.mustInvoke(testManufacturer, nil, "OrderContract:ActivateDealer", pending.name)
+	if l.lastEvent() != EventDealerActivated {
+		t.Fatalf("event = %s, want %s", l.lastEvent(), EventDealerActivated)
+	}
+	l.mustFail(testManufacturer, nil, "already Active", "OrderContract:ActivateDealer", pending.name)
+	l.mustInvoke(pending, transient, "OrderContract:CreateOrder", "O2")
+	l.mustFail(pending, transient, "already exists", "OrderContract:CreateOrder", "O1")
+}
+
+func TestOrderScope(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke(testAdmin, nil, "AccessContract:SetRoleMSPs", RoleDealer, `["Org2MSP","Org4MSP"]`)
+	other := testIdentity{"Org4MSP", RoleDealer, "city"}
+	addDealer(l, testDealer)
+	addDealer(l, other)
+	placeOrder(l, testDealer, "O1", "Maruti", "Alto", "Red")
+	placeOrder(l, other, "O2", "Maruti", "Swift", "Blue")
+
+	if _, ok := l.private[DealerCollection(testDealer.name)]["O1"]; !ok {
+		t.Fatalf("O1 is not in the collection of %s", testDealer.name)
+	}
+	l.mustInvoke(testDealer, nil, "OrderContract:ReadOrder", "O1")
+	l.mustFail(testDealer, nil, "does not exist", "OrderContract:ReadOrder", "O2")
+	// a same-named identity of another org is not the dealer
+	impostor := testIdentity{"Org4MSP", RoleDealer, testDealer.name}
+	l.mustFail(impostor, nil, "does not exist", "OrderContract:ReadOrder", "O1")
+
+	var orders []*Order
+	if err := json.Unmarshal([]byte(l.mustInvoke(testManufacturer, nil, "OrderContract:GetAllOrders")), &orders); err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("the manufacturer sees %d orders, want 2", len(orders))
+	}
+}
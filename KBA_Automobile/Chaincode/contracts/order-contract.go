@@ -3,6 +3,8 @@ package contracts
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
 	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
@@ -20,166 +22,583 @@ type Order struct {
 	Make       string `json:"make"`
 	Model      string `json:"model"`
 	OrderID    string `json:"orderID"`
+
+	// DealerMspId is the org of the dealer that placed the order
+	DealerMspId string `json:"dealerMspId,omitempty" metadata:",optional"`
+	// Collection is the private data collection of the dealer holding the
+	// order, empty for orders kept in the shared OrderCollection
+	Collection string `json:"collection,omitempty" metadata:",optional"`
+
+	Status      OrderStatus        `json:"status"`
+	Quantity    int                `json:"quantity"`
+	Remaining   int                `json:"remaining"`
+	Allocations []*OrderAllocation `json:"allocations,omitempty" metadata:",optional"`
+	CreatedAt   string             `json:"createdAt,omitempty" metadata:",optional"`
+	MatchedAt   string             `json:"matchedAt,omitempty" metadata:",optional"`
+	DeliveredAt string             `json:"deliveredAt,omitempty" metadata:",optional"`
+	CancelledAt string             `json:"cancelledAt,omitempty" metadata:",optional"`
 }
 
-func getCollectionName() string {
-	collectionName := "OrderCollection"
-	return collectionName
+// OrderAllocation is one car matched to an order
+type OrderAllocation struct {
+	CarId       string `json:"carId"`
+	AllocatedAt string `json:"allocatedAt"`
+	TxId        string `json:"txId"`
 }
 
-// OrderExists returns true when asset with given ID exists in private data collection
-func (o *OrderContract) OrderExists(ctx contractapi.TransactionContextInterface, orderID string) (bool, error) {
-	collectionName := getCollectionName()
+// OrderFulfillment is how far an order has been fulfilled
+type OrderFulfillment struct {
+	OrderID   string      `json:"orderID"`
+	Status    OrderStatus `json:"status"`
+	Quantity  int         `json:"quantity"`
+	Fulfilled int         `json:"fulfilled"`
+	Remaining int         `json:"remaining"`
+	CarIds    []string    `json:"carIds"`
+}
+
+// maxOrderQuantity is the largest number of cars one order may ask for
+const maxOrderQuantity = 1000
+
+// Composite-key indexes kept in the order collection. The attributes index
+// only holds pending orders, so that orders can be matched to cars without a
+// CouchDB rich query. The order ID is the last attribute of an index key.
+const (
+	orderAttributesIndex = "order~make~model~color"
+	orderStatusIndex     = "orderStatus~status~orderId"
+)
+
+// orderIndexKeys returns the composite index keys of the given order
+func orderIndexKeys(ctx contractapi.TransactionContextInterface, order *Order) ([]string, error) {
+	indexes := []compositeIndex{
+		{orderStatusIndex, []string{string(order.Status), order.OrderID}},
+	}
+	if order.Status == OrderPending {
+		indexes = append(indexes, compositeIndex{orderAttributesIndex, []string{order.Make, order.Model, order.Color, order.OrderID}})
+	}
+
+	var keys []string
+	for _, index := range indexes {
+		key, err := ctx.GetStub().CreateCompositeKey(index.objectType, index.attributes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s index key for order %s: %v", index.objectType, order.OrderID, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// putOrder writes the order to the order collection and brings its index keys
+// in line with its status, like putCar does for cars
+func putOrder(ctx contractapi.TransactionContextInterface, order *Order) error {
+	stub := ctx.GetStub()
+	collectionName := orderCollection(order)
 
-	data, err := ctx.GetStub().GetPrivateDataHash(collectionName, orderID)
+	newKeys, err := orderIndexKeys(ctx, order)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(newKeys))
+	for _, key := range newKeys {
+		keep[key] = true
+	}
 
+	stored, err := stub.GetPrivateData(collectionName, order.OrderID)
 	if err != nil {
+		return err
+	}
+	if stored != nil {
+		var previous Order
+		if err := json.Unmarshal(stored, &previous); err != nil {
+			return fmt.Errorf("Could not unmarshal private data collection data to type Order")
+		}
+		normalizeLegacyOrder(&previous)
+		oldKeys, err := orderIndexKeys(ctx, &previous)
+		if err != nil {
+			return err
+		}
+		for _, key := range oldKeys {
+			if keep[key] {
+				continue
+			}
+			if err := stub.DelPrivateData(collectionName, key); err != nil {
+				return err
+			}
+		}
+	}
+
+	bytes, _ := json.Marshal(order)
+	if err := stub.PutPrivateData(collectionName, order.OrderID, bytes); err != nil {
+		return err
+	}
+	for _, key := range newKeys {
+		if err := stub.PutPrivateData(collectionName, key, indexValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OrderExists returns true when asset with given ID exists in private data collection
+func (o *OrderContract) OrderExists(ctx contractapi.TransactionContextInterface, orderID string) (bool, error) {
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer); err != nil {
 		return false, err
 	}
+	return orderExists(ctx, orderID)
+}
 
-	return data != nil, nil
+// orderExists returns true when the order is in the caller's order scope
+func orderExists(ctx contractapi.TransactionContextInterface, orderID string) (bool, error) {
+	scope, err := getOrderScope(ctx)
+	if err != nil {
+		return false, err
+	}
+	order, err := scope.getOrder(ctx, orderID)
+	if err != nil {
+		return false, err
+	}
+	return order != nil, nil
 }
 
-// CreateOrder creates a new instance of Order
+// CreateOrder creates a new instance of Order in the collection of the
+// calling dealer, who must have been registered with RegisterDealer and
+// activated with ActivateDealer. Order IDs are unique across the collections
+// of all the dealers.
 func (o *OrderContract) CreateOrder(ctx contractapi.TransactionContextInterface, orderID string) (string, error) {
+	if err := requireRole(ctx, RoleDealer); err != nil {
+		return "", err
+	}
 
-	clientOrgID, err := ctx.GetClientIdentity().GetMSPID()
+	dealer, err := getCallerDealer(ctx)
 	if err != nil {
 		return "", err
 	}
-	if clientOrgID == "Org2MSP" {
-		exists, err := o.OrderExists(ctx, orderID)
-		if err != nil {
-			return "", fmt.Errorf("Could not read from world state. %s", err)
-		} else if exists {
-			return "", fmt.Errorf("The asset %s already exists", orderID)
-		}
+	if dealer == nil {
+		return "", fmt.Errorf("forbidden: the caller is not a registered dealer of its org, the manufacturer must register it first")
+	}
+	if !dealer.isActive() {
+		return "", fmt.Errorf("forbidden: dealer %s is %s, the manufacturer must activate it once its collection is deployed", dealer.Name, DealerPending)
+	}
+	scope, err := allOrdersScope(ctx)
+	if err != nil {
+		return "", err
+	}
+	exists, err := scope.holds(ctx, orderID)
+	if err != nil {
+		return "", fmt.Errorf("Could not read from world state. %s", err)
+	} else if exists {
+		return "", fmt.Errorf("The asset %s already exists", orderID)
+	}
+	purged, err := getOrderPurgeRecord(ctx, orderID)
+	if err != nil {
+		return "", err
+	}
+	if purged != nil {
+		return "", fmt.Errorf("order %s was purged, its ID cannot be used again", orderID)
+	}
 
-		order := new(Order)
+	order := new(Order)
 
-		transientData, err := ctx.GetStub().GetTransient()
-		if err != nil {
-			return "", err
-		}
+	transientData, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", err
+	}
 
-		if len(transientData) == 0 {
-			return "", fmt.Errorf("Please provide the private data of make, model, color, dealerName")
-		}
+	if len(transientData) == 0 {
+		return "", fmt.Errorf("Please provide the private data of make, model, color")
+	}
 
-		make, exists := transientData["make"]
-		if !exists {
-			return "", fmt.Errorf("The make was not specified in transient data. Please try again")
-		}
-		order.Make = string(make)
+	make, exists := transientData["make"]
+	if !exists {
+		return "", fmt.Errorf("The make was not specified in transient data. Please try again")
+	}
+	order.Make = string(make)
 
-		model, exists := transientData["model"]
-		if !exists {
-			return "", fmt.Errorf("The model was not specified in transient data. Please try again")
-		}
-		order.Model = string(model)
+	model, exists := transientData["model"]
+	if !exists {
+		return "", fmt.Errorf("The model was not specified in transient data. Please try again")
+	}
+	order.Model = string(model)
 
-		color, exists := transientData["color"]
-		if !exists {
-			return "", fmt.Errorf("The color was not specified in transient data. Please try again")
-		}
-		order.Color = string(color)
+	color, exists := transientData["color"]
+	if !exists {
+		return "", fmt.Errorf("The color was not specified in transient data. Please try again")
+	}
+	order.Color = string(color)
+
+	// orders are placed by dealers for themselves; dealerName is optional
+	if dealerName, exists := transientData["dealerName"]; exists && string(dealerName) != dealer.Name {
+		return "", fmt.Errorf("forbidden: dealer %s cannot place orders for %s", dealer.Name, dealerName)
+	}
+	order.DealerName = dealer.Name
 
-		dealerName, exists := transientData["dealerName"]
-		if !exists {
-			return "", fmt.Errorf("The dealer was not specified in transient data. Please try again")
+	order.Quantity = 1
+	if quantity, exists := transientData["quantity"]; exists {
+		order.Quantity, err = strconv.Atoi(strings.TrimSpace(string(quantity)))
+		if err != nil || order.Quantity < 1 || order.Quantity > maxOrderQuantity {
+			return "", fmt.Errorf("The quantity must be a whole number from 1 to %d, got %q", maxOrderQuantity, quantity)
 		}
-		order.DealerName = string(dealerName)
+	}
+	order.Remaining = order.Quantity
 
-		order.AssetType = "Order"
-		order.OrderID = orderID
+	order.DealerMspId = dealer.MspId
+	order.Collection = dealer.Collection
 
-		bytes, _ := json.Marshal(order)
+	createdAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return "", err
+	}
 
-		collectionName := getCollectionName()
+	order.AssetType = "Order"
+	order.OrderID = orderID
+	order.Status = OrderPending
+	order.CreatedAt = createdAt
 
-		return fmt.Sprintf("Order with id %v added successfully", orderID), ctx.GetStub().PutPrivateData(collectionName, orderID, bytes)
-	} else {
-		return fmt.Sprintf("Order cannot be created by organisation with MSPID %v ", clientOrgID), nil
+	err = putOrder(ctx, order)
+	if err != nil {
+		return "", err
 	}
+
+	return fmt.Sprintf("Order with id %v added successfully", orderID), emitOrderEvent(ctx, EventOrderCreated, orderID, "")
 }
 
 // ReadOrder retrieves an instance of Order from the private data collection
 func (o *OrderContract) ReadOrder(ctx contractapi.TransactionContextInterface, orderID string) (*Order, error) {
-	exists, err := o.OrderExists(ctx, orderID)
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer); err != nil {
+		return nil, err
+	}
+
+	return readOrder(ctx, orderID)
+}
+
+// ReadPrivateState reads an order from the collections in the caller's order
+// scope
+func ReadPrivateState(ctx contractapi.TransactionContextInterface, orderID string) (*Order, error) {
+	scope, err := getOrderScope(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("Could not read from world state. %s", err)
-	} else if !exists {
+		return nil, err
+	}
+	order, err := scope.getOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
 		return nil, fmt.Errorf("The asset %s does not exist", orderID)
 	}
+	return order, nil
+}
+
+// MarkOrderDelivered records that the dealer has received the car matched to
+// the order
+func (o *OrderContract) MarkOrderDelivered(ctx contractapi.TransactionContextInterface, orderID string) (*Order, error) {
+	if err := requireRole(ctx, RoleDealer); err != nil {
+		return nil, err
+	}
 
-	return ReadPrivateState(ctx, orderID)
+	order, err := readOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	dealer, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !isParty(dealer, order.DealerName, order.DealerMspId) {
+		return nil, fmt.Errorf("forbidden: only the dealer %s can confirm the delivery of order %s", order.DealerName, orderID)
+	}
+	if err := transitionOrder(order, OrderDelivered); err != nil {
+		return nil, err
+	}
+	order.DeliveredAt, err = getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
 
+	err = putOrder(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	err = emitOrderEvent(ctx, EventOrderDelivered, orderID, "")
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
 }
 
-func ReadPrivateState(ctx contractapi.TransactionContextInterface, orderID string) (*Order, error) {
-	collectionName := getCollectionName()
+// CancelOrder withdraws a pending order. The dealer who placed it and the
+// manufacturer may cancel it. The order is kept with the Cancelled status.
+func (o *OrderContract) CancelOrder(ctx contractapi.TransactionContextInterface, orderID string) (*Order, error) {
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer); err != nil {
+		return nil, err
+	}
 
-	bytes, err := ctx.GetStub().GetPrivateData(collectionName, orderID)
+	order, err := readOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	isManufacturer, err := hasRole(ctx, RoleManufacturer)
+	if err != nil {
+		return nil, err
+	}
+	if !isManufacturer {
+		dealer, err := getSubmitter(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !isParty(dealer, order.DealerName, order.DealerMspId) {
+			return nil, fmt.Errorf("forbidden: only the dealer %s or the manufacturer can cancel order %s", order.DealerName, orderID)
+		}
+	}
+	if err := transitionOrder(order, OrderCancelled); err != nil {
+		return nil, err
+	}
+	order.CancelledAt, err = getTxTimestamp(ctx)
 	if err != nil {
 		return nil, err
 	}
-	order := new(Order)
 
-	err = json.Unmarshal(bytes, order)
+	err = putOrder(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	err = emitOrderEvent(ctx, EventOrderCancelled, orderID, "")
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// GetOrdersByStatus retrieves the orders in the given status, e.g. the
+// delivered orders for fulfillment reporting
+func (o *OrderContract) GetOrdersByStatus(ctx contractapi.TransactionContextInterface, status string) ([]*Order, error) {
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer); err != nil {
+		return nil, err
+	}
 
+	if _, ok := orderStatusTransitions[OrderStatus(status)]; !ok {
+		return nil, fmt.Errorf("unknown order status %s", status)
+	}
+	orders, err := getOrdersByIndex(ctx, orderStatusIndex, []string{status})
 	if err != nil {
-		return nil, fmt.Errorf("Could not unmarshal private data collection data to type Order")
+		return nil, err
+	}
+	if orders == nil {
+		orders = []*Order{}
 	}
+	return orders, nil
+}
 
-	return order, nil
+// GetOrderFulfillment returns the number of cars ordered, allocated and still
+// to be allocated for an order
+func (o *OrderContract) GetOrderFulfillment(ctx contractapi.TransactionContextInterface, orderID string) (*OrderFulfillment, error) {
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer); err != nil {
+		return nil, err
+	}
+
+	order, err := readOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	fulfillment := &OrderFulfillment{
+		OrderID:   order.OrderID,
+		Status:    order.Status,
+		Quantity:  order.Quantity,
+		Fulfilled: len(order.Allocations),
+		Remaining: order.Remaining,
+		CarIds:    []string{},
+	}
+	for _, allocation := range order.Allocations {
+		fulfillment.CarIds = append(fulfillment.CarIds, allocation.CarId)
+	}
+	return fulfillment, nil
 }
 
-// DeleteOrder deletes an instance of Order from the private data collection
-func (o *OrderContract) DeleteOrder(ctx contractapi.TransactionContextInterface, orderID string) error {
-	clientOrgID, err := ctx.GetClientIdentity().GetMSPID()
+// allocateCar records that the car goes to the order and counts down the cars
+// still to be allocated. The order becomes Matched with its last car; until
+// then it stays Pending and can be matched again.
+func allocateCar(ctx contractapi.TransactionContextInterface, order *Order, carID string) error {
+	if order.Status != OrderPending || order.Remaining < 1 {
+		return fmt.Errorf("order %s is %s and takes no more cars", order.OrderID, order.Status)
+	}
+	allocatedAt, err := getTxTimestamp(ctx)
 	if err != nil {
 		return err
 	}
-	if clientOrgID == "Org1MSP" || clientOrgID == "Org2MSP" {
-		exists, err := o.OrderExists(ctx, orderID)
 
-		if err != nil {
-			return fmt.Errorf("Could not read from world state. %s", err)
-		} else if !exists {
-			return fmt.Errorf("The asset %s does not exist", orderID)
+	order.Allocations = append(order.Allocations, &OrderAllocation{
+		CarId:       carID,
+		AllocatedAt: allocatedAt,
+		TxId:        ctx.GetStub().GetTxID(),
+	})
+	order.Remaining--
+	if order.Remaining == 0 {
+		if err := transitionOrder(order, OrderMatched); err != nil {
+			return err
 		}
+		order.MatchedAt = allocatedAt
+	}
+	return nil
+}
 
-		collectionName := getCollectionName()
+// readOrder returns the order, or an error when it does not exist
+func readOrder(ctx contractapi.TransactionContextInterface, orderID string) (*Order, error) {
+	return ReadPrivateState(ctx, orderID)
+}
 
-		return ctx.GetStub().DelPrivateData(collectionName, orderID)
-	} else {
-		return fmt.Errorf("Organisation with %v cannot delete the order", clientOrgID)
+// DeleteOrder deletes an instance of Order from the private data collection.
+// It is removed like PurgeOrder removes orders, so it does not stay in the
+// private data history either where purging is enabled.
+func (o *OrderContract) DeleteOrder(ctx contractapi.TransactionContextInterface, orderID string) error {
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer); err != nil {
+		return err
 	}
+
+	exists, err := orderExists(ctx, orderID)
+
+	if err != nil {
+		return fmt.Errorf("Could not read from world state. %s", err)
+	} else if !exists {
+		return fmt.Errorf("The asset %s does not exist", orderID)
+	}
+
+	order, err := ReadPrivateState(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	_, err = purgeOrder(ctx, order, "deleted")
+	if err != nil {
+		return err
+	}
+
+	return emitOrderEvent(ctx, EventOrderDeleted, orderID, "")
 }
 
-// GetAllOrders retrieves all the asset with assetype 'Order'
+// GetAllOrders retrieves all the asset with assetype 'Order' in the caller's
+// order scope: every order for the manufacturer, its own orders for a dealer
 func (o *OrderContract) GetAllOrders(ctx contractapi.TransactionContextInterface) ([]*Order, error) {
-	collectionName := getCollectionName()
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer); err != nil {
+		return nil, err
+	}
+	scope, err := getOrderScope(ctx)
+	if err != nil {
+		return nil, err
+	}
 	queryString := `{"selector":{"assetType":"Order"}}`
-	resultsIterator, err := ctx.GetStub().GetPrivateDataQueryResult(collectionName, queryString)
+	var orders []*Order
+	for _, collectionName := range scope.collections {
+		resultsIterator, err := ctx.GetStub().GetPrivateDataQueryResult(collectionName, queryString)
+		if err != nil {
+			return nil, err
+		}
+		found, err := orderResultIteratorFunction(resultsIterator)
+		resultsIterator.Close()
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, scope.filter(found)...)
+	}
+	return orders, nil
+}
+
+// GetOrdersByRange gives a range of order details based on a start key and an
+// end key, from the collections in the caller's order scope
+
+func (o *OrderContract) GetOrdersByRange(ctx contractapi.TransactionContextInterface, startKey string, endKey string) ([]*Order, error) {
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer); err != nil {
+		return nil, err
+	}
+	scope, err := getOrderScope(ctx)
 	if err != nil {
+		return nil, err
+	}
+	var orders []*Order
+	for _, collectionName := range scope.collections {
+		resultsIterator, err := ctx.GetStub().GetPrivateDataByRange(collectionName, startKey, endKey)
+		if err != nil {
+			return nil, err
+		}
+		found, err := orderResultIteratorFunction(resultsIterator)
+		resultsIterator.Close()
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, scope.filter(found)...)
+	}
+	sortOrdersByID(orders)
+	return orders, nil
+}
 
+// GetAllOrdersWithPagination retrieves one page of the orders in the private
+// data collection. Pass the returned bookmark to fetch the next page.
+func (o *OrderContract) GetAllOrdersWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedOrderResult, error) {
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer); err != nil {
 		return nil, err
 	}
-	defer resultsIterator.Close()
-	return orderResultIteratorFunction(resultsIterator)
+	return getOrdersPage(ctx, "", "", pageSize, bookmark)
+}
+
+// GetOrdersByRangeWithPagination retrieves one page of the orders with keys in
+// [startKey, endKey). Pass the returned bookmark to fetch the next page.
+func (o *OrderContract) GetOrdersByRangeWithPagination(ctx contractapi.TransactionContextInterface, startKey string, endKey string, pageSize int32, bookmark string) (*PaginatedOrderResult, error) {
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer); err != nil {
+		return nil, err
+	}
+	return getOrdersPage(ctx, startKey, endKey, pageSize, bookmark)
 }
 
-// GetOrdersByRange gives a range of order details based on a start key and an end key
+// getOrdersByAttributes returns the pending orders for the given make, model
+// and color
+func getOrdersByAttributes(ctx contractapi.TransactionContextInterface, make string, model string, color string) ([]*Order, error) {
+	return getOrdersByIndex(ctx, orderAttributesIndex, []string{make, model, color})
+}
 
-func (o *OrderContract) GetOrdersByRange(ctx contractapi.TransactionContextInterface, startKey string, endKey string) ([]*Order, error) {
-	collectionName := getCollectionName()
-	resultsIterator, err := ctx.GetStub().GetPrivateDataByRange(collectionName, startKey, endKey)
+// getOrdersByIndex returns the orders in the caller's order scope whose index
+// keys start with the given attributes
+func getOrdersByIndex(ctx contractapi.TransactionContextInterface, objectType string, attributes []string) ([]*Order, error) {
+	scope, err := getOrderScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var orders []*Order
+	for _, collectionName := range scope.collections {
+		found, err := getOrdersByIndexFrom(ctx, collectionName, objectType, attributes)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, scope.filter(found)...)
+	}
+	return orders, nil
+}
+
+// getOrdersByIndexFrom returns the orders of one collection whose index keys
+// start with the given attributes
+func getOrdersByIndexFrom(ctx contractapi.TransactionContextInterface, collectionName string, objectType string, attributes []string) ([]*Order, error) {
+	stub := ctx.GetStub()
+	resultsIterator, err := stub.GetPrivateDataByPartialCompositeKey(collectionName, objectType, attributes)
 	if err != nil {
 		return nil, err
 	}
 	defer resultsIterator.Close()
-	return orderResultIteratorFunction(resultsIterator)
+
+	var orders []*Order
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, keyParts, err := stub.SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, err
+		}
+		order, err := getOrderFrom(ctx, collectionName, keyParts[len(keyParts)-1])
+		if err != nil {
+			return nil, err
+		}
+		if order != nil {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
 }
 
 // iterator function
@@ -197,6 +616,7 @@ func orderResultIteratorFunction(resultsIterator shim.StateQueryIteratorInterfac
 		if err != nil {
 			return nil, err
 		}
+		normalizeLegacyOrder(&order)
 		orders = append(orders, &order)
 	}
 	return orders, nil
@@ -0,0 +1,64 @@
+package contracts
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// putSharedOrder writes an order of the dealer to the shared OrderCollection,
+// as earlier versions of this chaincode placed them
+func putSharedOrder(l *testLedger, dealer testIdentity, orderID string, status OrderStatus) {
+	l.t.Helper()
+	value, _ := json.Marshal(&Order{
+		AssetType:   "Order",
+		OrderID:     orderID,
+		DealerName:  dealer.name,
+		DealerMspId: dealer.mspID,
+		Make:        "Maruti",
+		Model:       "Alto",
+		Color:       "Red",
+		Status:      status,
+		Quantity:    1,
+	})
+	if l.private[OrderCollection] == nil {
+		l.private[OrderCollection] = map[string][]byte{}
+	}
+	l.private[OrderCollection][orderID] = value
+}
+
+func TestMarkOrderDelivered(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke(testAdmin, nil, "AccessContract:SetRoleMSPs", RoleDealer, `["Org2MSP","Org4MSP"]`)
+	addDealer(l, testDealer)
+	deliverCar(l, testDealer, "C1")
+	putSharedOrder(l, testDealer, "O2", OrderMatched)
+
+	// a same-named identity of another org is not the dealer
+	impostor := testIdentity{"Org4MSP", RoleDealer, testDealer.name}
+	l.mustFail(impostor, nil, "forbidden", "OrderContract:MarkOrderDelivered", "O2")
+	l.mustInvoke(testDealer, nil, "OrderContract:MarkOrderDelivered", "O2")
+	l.mustInvoke(testDealer, nil, "OrderContract:MarkOrderDelivered", "O-C1")
+	if l.lastEvent() != EventOrderDelivered {
+		t.Fatalf("event = %s, want %s", l.lastEvent(), EventOrderDelivered)
+	}
+	l.mustFail(testDealer, nil, "cannot move from Delivered", "OrderContract:MarkOrderDelivered", "O-C1")
+}
+
+func TestCancelOrder(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke(testAdmin, nil, "AccessContract:SetRoleMSPs", RoleDealer, `["Org2MSP","Org4MSP"]`)
+	addDealer(l, testDealer)
+	placeOrder(l, testDealer, "O1", "Maruti", "Alto", "Red")
+	putSharedOrder(l, testDealer, "O2", OrderPending)
+	putSharedOrder(l, testDealer, "O3", OrderPending)
+
+	impostor := testIdentity{"Org4MSP", RoleDealer, testDealer.name}
+	l.mustFail(impostor, nil, "forbidden", "OrderContract:CancelOrder", "O2")
+	l.mustInvoke(testDealer, nil, "OrderContract:CancelOrder", "O2")
+	l.mustInvoke(testManufacturer, nil, "OrderContract:CancelOrder", "O3")
+	l.mustInvoke(testDealer, nil, "OrderContract:CancelOrder", "O1")
+	if l.lastEvent() != EventOrderCancelled {
+		t.Fatalf("event = %s, want %s", l.lastEvent(), EventOrderCancelled)
+	}
+	l.mustFail(testDealer, nil, "cannot move from Cancelled", "OrderContract:CancelOrder", "O1")
+}
@@ -0,0 +1,280 @@
+package contracts
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// orderPurgeIndex is the composite key object type of the purge records in the
+// world state
+const orderPurgeIndex = "orderPurge~orderId"
+
+// settingIndex is the composite key object type of the chaincode settings in
+// the world state
+const settingIndex = "setting~name"
+
+// privateDataPurgeSetting is the name of the PurgeSetting
+const privateDataPurgeSetting = "privateDataPurge"
+
+// Methods an order is removed from its collection with, see OrderPurgeRecord
+const (
+	MethodPurgePrivateData = "PurgePrivateData"
+	MethodDelPrivateData   = "DelPrivateData"
+)
+
+// PurgeSetting tells whether orders are purged or deleted. Peers only accept
+// PurgePrivateData on channels with the V2_5 application capability, which
+// the chaincode cannot detect, so an admin enables it once the channel has
+// it. Until then orders are deleted with DelPrivateData.
+type PurgeSetting struct {
+	Enabled   bool       `json:"enabled"`
+	UpdatedBy *Submitter `json:"updatedBy,omitempty" metadata:",optional"`
+	UpdatedAt string     `json:"updatedAt,omitempty" metadata:",optional"`
+}
+
+// OrderPurgeRecord is the public proof that an order was removed from its
+// collection. OrderHash is the SHA-256 hash of the order as it was stored,
+// which the ledger already held, so it reveals nothing about the order while a
+// party holding a copy can check that this was the data removed. Method is
+// MethodPurgePrivateData when the order was purged from the private data
+// history of the peers too, MethodDelPrivateData when it was only deleted.
+type OrderPurgeRecord struct {
+	OrderId    string      `json:"orderId"`
+	Collection string      `json:"collection"`
+	OrderHash  string      `json:"orderHash"`
+	Status     OrderStatus `json:"status"`
+	Reason     string      `json:"reason"`
+	Method     string      `json:"method"`
+	PurgedBy   *Submitter  `json:"purgedBy"`
+	PurgedAt   string      `json:"purgedAt"`
+	TxId       string      `json:"txId"`
+}
+
+// PurgeOrder removes a matched, delivered or cancelled order and its index keys
+// from the collection, and from the private data history of every peer when
+// purging is enabled, and records the removal in the world state. It is
+// reserved to the admin role.
+func (o *OrderContract) PurgeOrder(ctx contractapi.TransactionContextInterface, orderID string, reason string) (*OrderPurgeRecord, error) {
+	if err := requireRole(ctx, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("a reason is required to purge order %s", orderID)
+	}
+	scope, err := allOrdersScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	order, err := scope.getOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, fmt.Errorf("The asset %s does not exist", orderID)
+	}
+	if order.Status == OrderPending {
+		return nil, fmt.Errorf("order %s is %s, only matched, delivered or cancelled orders can be purged", orderID, order.Status)
+	}
+
+	record, err := purgeOrder(ctx, order, reason)
+	if err != nil {
+		return nil, err
+	}
+	err = emitOrderEvent(ctx, EventOrderPurged, orderID, "")
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// SetPrivateDataPurge enables or disables purging orders with
+// PurgePrivateData. Enable it once the channel has the V2_5 application
+// capability; while it is disabled, PurgeOrder and DeleteOrder delete orders
+// with DelPrivateData. It is reserved to the admin role.
+func (o *OrderContract) SetPrivateDataPurge(ctx contractapi.TransactionContextInterface, enabled bool) (*PurgeSetting, error) {
+	if err := requireRole(ctx, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	updatedBy, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	updatedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	setting := &PurgeSetting{Enabled: enabled, UpdatedBy: updatedBy, UpdatedAt: updatedAt}
+
+	key, err := ctx.GetStub().CreateCompositeKey(settingIndex, []string{privateDataPurgeSetting})
+	if err != nil {
+		return nil, err
+	}
+	value, _ := json.Marshal(setting)
+	err = ctx.GetStub().PutState(key, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put to world state. %v", err)
+	}
+	err = emitPurgeSettingEvent(ctx, EventPrivateDataPurgeSet, setting)
+	if err != nil {
+		return nil, err
+	}
+	return setting, nil
+}
+
+// GetPrivateDataPurge returns whether orders are purged or deleted
+func (o *OrderContract) GetPrivateDataPurge(ctx contractapi.TransactionContextInterface) (*PurgeSetting, error) {
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer, RoleAdmin); err != nil {
+		return nil, err
+	}
+	return getPurgeSetting(ctx)
+}
+
+// getPurgeSetting returns the purge setting. Purging is disabled until an admin
+// enables it.
+func getPurgeSetting(ctx contractapi.TransactionContextInterface) (*PurgeSetting, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(settingIndex, []string{privateDataPurgeSetting})
+	if err != nil {
+		return nil, err
+	}
+	value, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if value == nil {
+		return &PurgeSetting{}, nil
+	}
+	var setting PurgeSetting
+	err = json.Unmarshal(value, &setting)
+	if err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
+
+// GetOrderPurgeRecord returns the record of the purge of an order
+func (o *OrderContract) GetOrderPurgeRecord(ctx contractapi.TransactionContextInterface, orderID string) (*OrderPurgeRecord, error) {
+	if err := requireRole(ctx, RoleManufacturer, RoleDealer, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	record, err := getOrderPurgeRecord(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, fmt.Errorf("order %s has not been purged", orderID)
+	}
+	return record, nil
+}
+
+// purgeOrder removes the order with every index key it may have had in any
+// status and writes the purge record. When purging is enabled the keys are
+// purged, so that none of the order's attributes stay in the private data
+// history; otherwise they are deleted, which channels without the V2_5
+// application capability accept.
+func purgeOrder(ctx contractapi.TransactionContextInterface, order *Order, reason string) (*OrderPurgeRecord, error) {
+	stub := ctx.GetStub()
+	collectionName := orderCollection(order)
+
+	setting, err := getPurgeSetting(ctx)
+	if err != nil {
+		return nil, err
+	}
+	remove, method := stub.DelPrivateData, MethodDelPrivateData
+	if setting.Enabled {
+		remove, method = stub.PurgePrivateData, MethodPurgePrivateData
+	}
+
+	hash, err := stub.GetPrivateDataHash(collectionName, order.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := orderHistoryKeys(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range append(keys, order.OrderID) {
+		if err := remove(collectionName, key); err != nil {
+			return nil, fmt.Errorf("failed to remove order %s with %s: %v", order.OrderID, method, err)
+		}
+	}
+
+	purgedBy, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	purgedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	record := &OrderPurgeRecord{
+		OrderId:    order.OrderID,
+		Collection: collectionName,
+		OrderHash:  hex.EncodeToString(hash),
+		Status:     order.Status,
+		Reason:     reason,
+		Method:     method,
+		PurgedBy:   purgedBy,
+		PurgedAt:   purgedAt,
+		TxId:       stub.GetTxID(),
+	}
+	key, err := stub.CreateCompositeKey(orderPurgeIndex, []string{order.OrderID})
+	if err != nil {
+		return nil, err
+	}
+	value, _ := json.Marshal(record)
+	err = stub.PutState(key, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put to world state. %v", err)
+	}
+	return record, nil
+}
+
+// orderHistoryKeys returns the index keys the order has had in any status
+func orderHistoryKeys(ctx contractapi.TransactionContextInterface, order *Order) ([]string, error) {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, status := range []OrderStatus{OrderPending, OrderMatched, OrderDelivered, OrderCancelled} {
+		past := *order
+		past.Status = status
+		statusKeys, err := orderIndexKeys(ctx, &past)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range statusKeys {
+			if !seen[key] {
+				keys = append(keys, key)
+				seen[key] = true
+			}
+		}
+	}
+	return keys, nil
+}
+
+// getOrderPurgeRecord returns the purge record of the order, or nil
+func getOrderPurgeRecord(ctx contractapi.TransactionContextInterface, orderID string) (*OrderPurgeRecord, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(orderPurgeIndex, []string{orderID})
+	if err != nil {
+		return nil, err
+	}
+	value, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	var record OrderPurgeRecord
+	err = json.Unmarshal(value, &record)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
@@ -0,0 +1,54 @@
+package contracts
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPurgeOrder(t *testing.T) {
+	l := newTestLedger(t)
+	addDealer(l, testDealer)
+	placeOrder(l, testDealer, "O1", "Maruti", "Alto", "Red")
+	placeOrder(l, testDealer, "O2", "Maruti", "Alto", "Red")
+	placeOrder(l, testDealer, "O3", "Maruti", "Alto", "Red")
+
+	l.mustFail(testAdmin, nil, "only matched, delivered or cancelled", "OrderContract:PurgeOrder", "O1", "done")
+	l.mustInvoke(testDealer, nil, "OrderContract:CancelOrder", "O1")
+	l.mustInvoke(testDealer, nil, "OrderContract:CancelOrder", "O2")
+	l.mustFail(testManufacturer, nil, "forbidden", "OrderContract:PurgeOrder", "O1", "done")
+
+	// purging is off until the channel has the V2_5 capability
+	record := purge(l, "O1")
+	if record.Method != MethodDelPrivateData || len(l.purged) != 0 {
+		t.Fatalf("method = %s with %d purged keys, want %s and none", record.Method, len(l.purged), MethodDelPrivateData)
+	}
+	if _, ok := l.private[DealerCollection(testDealer.name)]["O1"]; ok {
+		t.Fatal("O1 is still in its collection")
+	}
+
+	l.mustInvoke(testAdmin, nil, "OrderContract:SetPrivateDataPurge", "true")
+	if l.lastEvent() != EventPrivateDataPurgeSet {
+		t.Fatalf("event = %s, want %s", l.lastEvent(), EventPrivateDataPurgeSet)
+	}
+	record = purge(l, "O2")
+	if record.Method != MethodPurgePrivateData || len(l.purged) == 0 {
+		t.Fatalf("method = %s with %d purged keys, want %s", record.Method, len(l.purged), MethodPurgePrivateData)
+	}
+
+	l.mustInvoke(testDealer, nil, "OrderContract:DeleteOrder", "O3")
+	l.mustInvoke(testDealer, nil, "OrderContract:GetOrderPurgeRecord", "O3")
+	l.mustFail(testDealer, map[string][]byte{"make": []byte("Maruti"), "model": []byte("Alto"), "color": []byte("Red")}, "cannot be used again", "OrderContract:CreateOrder", "O3")
+}
+
+// purge purges the order and returns its purge record
+func purge(l *testLedger, orderID string) *OrderPurgeRecord {
+	l.t.Helper()
+	var record OrderPurgeRecord
+	if err := json.Unmarshal([]byte(l.mustInvoke(testAdmin, nil, "OrderContract:PurgeOrder", orderID, "retention")), &record); err != nil {
+		l.t.Fatal(err)
+	}
+	if record.OrderHash == "" || record.Reason != "retention" {
+		l.t.Fatalf("incomplete purge record %+v", record)
+	}
+	return &record
+}
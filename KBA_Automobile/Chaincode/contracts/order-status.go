@@ -0,0 +1,78 @@
+package contracts
+
+import (
+	"fmt"
+	"sort"
+)
+
+// OrderStatus is the fulfillment state of an Order
+type OrderStatus string
+
+// Fulfillment states of an Order. An order waits as Pending until the
+// manufacturer has matched all the cars it asks for, and is Delivered once the
+// dealer has received them. Only pending orders can be cancelled.
+const (
+	OrderPending   OrderStatus = "Pending"
+	OrderMatched   OrderStatus = "Matched"
+	OrderDelivered OrderStatus = "Delivered"
+	OrderCancelled OrderStatus = "Cancelled"
+)
+
+// orderStatusTransitions lists, for each state, the states an order may move to next
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderPending:   {OrderMatched, OrderCancelled},
+	OrderMatched:   {OrderDelivered},
+	OrderDelivered: {},
+	OrderCancelled: {},
+}
+
+// CanTransitionTo returns true when an order in status s may move to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionOrder moves the order to the next status, rejecting moves that are
+// not in the transition table
+func transitionOrder(order *Order, next OrderStatus) error {
+	if !order.Status.CanTransitionTo(next) {
+		return fmt.Errorf("order %s cannot move from %s to %s", order.OrderID, order.Status, next)
+	}
+	order.Status = next
+	return nil
+}
+
+// normalizeLegacyOrder gives orders written by earlier versions of this
+// chaincode, which had no status, the Pending status. Orders used to be
+// deleted once matched, so every order without a status is still pending.
+// Orders without a quantity were for a single car.
+func normalizeLegacyOrder(order *Order) {
+	if order.Status == "" {
+		order.Status = OrderPending
+	}
+	if order.Quantity == 0 {
+		order.Quantity = 1
+		if order.Status == OrderPending {
+			order.Remaining = 1
+		}
+	}
+}
+
+// rankOrders sorts orders oldest first, then by the number of cars still to
+// allocate, most first, then by order ID so that the ranking is the same on
+// every peer
+func rankOrders(orders []*Order) {
+	sort.SliceStable(orders, func(i, j int) bool {
+		if orders[i].CreatedAt != orders[j].CreatedAt {
+			return orders[i].CreatedAt < orders[j].CreatedAt
+		}
+		if orders[i].Remaining != orders[j].Remaining {
+			return orders[i].Remaining > orders[j].Remaining
+		}
+		return orders[i].OrderID < orders[j].OrderID
+	})
+}
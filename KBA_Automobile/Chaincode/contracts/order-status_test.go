@@ -0,0 +1,117 @@
+package contracts
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrderStatusTransitions(t *testing.T) {
+	statuses := []OrderStatus{OrderPending, OrderMatched, OrderDelivered, OrderCancelled}
+	allowed := map[OrderStatus]map[OrderStatus]bool{
+		OrderPending:   {OrderMatched: true, OrderCancelled: true},
+		OrderMatched:   {OrderDelivered: true},
+		OrderDelivered: {},
+		OrderCancelled: {},
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[from][to]
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", from, to, got, want)
+			}
+
+			order := &Order{OrderID: "O1", Status: from}
+			err := transitionOrder(order, to)
+			if want && (err != nil || order.Status != to) {
+				t.Errorf("transitionOrder from %s to %s = %v, status %s", from, to, err, order.Status)
+			}
+			if !want && (err == nil || order.Status != from) {
+				t.Errorf("transitionOrder from %s to %s succeeded, status %s", from, to, order.Status)
+			}
+		}
+	}
+}
+
+func TestRankOrders(t *testing.T) {
+	tests := []struct {
+		name   string
+		orders []*Order
+		want   []string
+	}{
+		{
+			name: "oldest first",
+			orders: []*Order{
+				{OrderID: "O1", CreatedAt: "2024-01-03T00:00:00Z", Remaining: 1},
+				{OrderID: "O2", CreatedAt: "2024-01-01T00:00:00Z", Remaining: 1},
+				{OrderID: "O3", CreatedAt: "2024-01-02T00:00:00Z", Remaining: 1},
+			},
+			want: []string{"O2", "O3", "O1"},
+		},
+		{
+			name: "most cars remaining first at the same time",
+			orders: []*Order{
+				{OrderID: "O1", CreatedAt: "2024-01-01T00:00:00Z", Remaining: 1},
+				{OrderID: "O2", CreatedAt: "2024-01-01T00:00:00Z", Remaining: 5},
+				{OrderID: "O3", CreatedAt: "2024-01-01T00:00:00Z", Remaining: 3},
+			},
+			want: []string{"O2", "O3", "O1"},
+		},
+		{
+			name: "order ID breaks ties",
+			orders: []*Order{
+				{OrderID: "O3", CreatedAt: "2024-01-01T00:00:00Z", Remaining: 2},
+				{OrderID: "O1", CreatedAt: "2024-01-01T00:00:00Z", Remaining: 2},
+				{OrderID: "O2", CreatedAt: "2024-01-01T00:00:00Z", Remaining: 2},
+			},
+			want: []string{"O1", "O2", "O3"},
+		},
+		{
+			name: "legacy orders without a creation time come first",
+			orders: []*Order{
+				{OrderID: "O2", CreatedAt: "2024-01-01T00:00:00Z", Remaining: 1},
+				{OrderID: "O1", Remaining: 1},
+			},
+			want: []string{"O1", "O2"},
+		},
+		{
+			name:   "no orders",
+			orders: []*Order{},
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rankOrders(tt.orders)
+			got := make([]string, len(tt.orders))
+			for i, order := range tt.orders {
+				got[i] = order.OrderID
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("rankOrders = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeLegacyOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		order     Order
+		status    OrderStatus
+		quantity  int
+		remaining int
+	}{
+		{"legacy order", Order{}, OrderPending, 1, 1},
+		{"current order", Order{Status: OrderPending, Quantity: 3, Remaining: 2}, OrderPending, 3, 2},
+		{"matched without a quantity", Order{Status: OrderMatched}, OrderMatched, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := tt.order
+			normalizeLegacyOrder(&order)
+			if order.Status != tt.status || order.Quantity != tt.quantity || order.Remaining != tt.remaining {
+				t.Fatalf("normalizeLegacyOrder = %s, %d, %d, want %s, %d, %d", order.Status, order.Quantity, order.Remaining, tt.status, tt.quantity, tt.remaining)
+			}
+		})
+	}
+}
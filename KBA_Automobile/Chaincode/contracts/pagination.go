@@ -0,0 +1,108 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// maxPageSize caps the number of records a paginated query returns at once
+const maxPageSize = 1000
+
+// PaginatedCarResult is one page of cars together with the bookmark to pass
+// in to fetch the next page
+type PaginatedCarResult struct {
+	Records             []*Car `json:"records"`
+	FetchedRecordsCount int32  `json:"fetchedRecordsCount"`
+	Bookmark            string `json:"bookmark"`
+}
+
+// PaginatedOrderResult is one page of orders together with the bookmark to
+// pass in to fetch the next page
+type PaginatedOrderResult struct {
+	Records             []*Order `json:"records"`
+	FetchedRecordsCount int32    `json:"fetchedRecordsCount"`
+	Bookmark            string   `json:"bookmark"`
+}
+
+func validatePageSize(pageSize int32) error {
+	if pageSize < 1 || pageSize > maxPageSize {
+		return fmt.Errorf("page size must be between 1 and %d, got %d", maxPageSize, pageSize)
+	}
+	return nil
+}
+
+// getOrdersPage returns one page of the orders with keys in [startKey, endKey)
+// from the collections in the caller's order scope. The shim has no paginated
+// queries over private data, so the page is cut here and the bookmark is the
+// order ID the next page starts at, or empty on the last page. Order IDs are
+// unique across collections, so the pages follow the order IDs.
+func getOrdersPage(ctx contractapi.TransactionContextInterface, startKey string, endKey string, pageSize int32, bookmark string) (*PaginatedOrderResult, error) {
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+	scope, err := getOrderScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return getScopedOrdersPage(ctx, scope, startKey, endKey, pageSize, bookmark)
+}
+
+// getScopedOrdersPage returns one page of the orders of the given scope with
+// keys in [startKey, endKey), see getOrdersPage
+func getScopedOrdersPage(ctx contractapi.TransactionContextInterface, scope *orderScope, startKey string, endKey string, pageSize int32, bookmark string) (*PaginatedOrderResult, error) {
+	if bookmark > startKey {
+		startKey = bookmark
+	}
+
+	// one more than the page from each collection tells where the next page starts
+	var orders []*Order
+	for _, collectionName := range scope.collections {
+		found, err := getOrdersFrom(ctx, scope, collectionName, startKey, endKey, pageSize+1)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, found...)
+	}
+	sortOrdersByID(orders)
+
+	page := &PaginatedOrderResult{Records: []*Order{}}
+	for _, order := range orders {
+		if int32(len(page.Records)) == pageSize {
+			page.Bookmark = order.OrderID
+			break
+		}
+		page.Records = append(page.Records, order)
+	}
+	page.FetchedRecordsCount = int32(len(page.Records))
+	return page, nil
+}
+
+// getOrdersFrom returns up to limit orders of the scope with keys in
+// [startKey, endKey) from one collection
+func getOrdersFrom(ctx contractapi.TransactionContextInterface, scope *orderScope, collectionName string, startKey string, endKey string, limit int32) ([]*Order, error) {
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByRange(collectionName, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var orders []*Order
+	for resultsIterator.HasNext() && int32(len(orders)) < limit {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var order Order
+		err = json.Unmarshal(queryResult.Value, &order)
+		if err != nil {
+			return nil, err
+		}
+		normalizeLegacyOrder(&order)
+		if scope.allows(&order) {
+			orders = append(orders, &order)
+		}
+	}
+	return orders, nil
+}
@@ -0,0 +1,491 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// RecallContract contract for managing manufacturer recall campaigns
+type RecallContract struct {
+	contractapi.Contract
+}
+
+// recallIndex is the composite key object type of recall campaigns. Composite
+// keys keep campaigns out of the range scans over cars.
+const recallIndex = "recall~recallId"
+
+// Recall is a recall campaign covering the cars of one make and model built in
+// a range of manufacture dates. The affected cars are worked out when the
+// campaign is issued.
+type Recall struct {
+	AssetType        string     `json:"assetType"`
+	RecallId         string     `json:"recallId"`
+	Make             string     `json:"make"`
+	Model            string     `json:"model"`
+	ManufacturedFrom string     `json:"manufacturedFrom"`
+	ManufacturedTo   string     `json:"manufacturedTo"`
+	Description      string     `json:"description"`
+	Safety           bool       `json:"safety"`
+	BlocksTransfers  bool       `json:"blocksTransfers"`
+	IssuedBy         *Submitter `json:"issuedBy"`
+	IssuedAt         string     `json:"issuedAt"`
+	AffectedCars     []string   `json:"affectedCars"`
+	// UndatedCars are the cars of the make and model whose date of
+	// manufacture cannot be read. They are not marked and need to be checked
+	// by hand.
+	UndatedCars []string `json:"undatedCars,omitempty" metadata:",optional"`
+}
+
+// CarRecall is the state of one recall on an affected car
+type CarRecall struct {
+	RecallId        string     `json:"recallId"`
+	Safety          bool       `json:"safety"`
+	BlocksTransfers bool       `json:"blocksTransfers,omitempty" metadata:",optional"`
+	Remediated      bool       `json:"remediated"`
+	RemediatedBy    *Submitter `json:"remediatedBy,omitempty" metadata:",optional"`
+	RemediatedAt    string     `json:"remediatedAt,omitempty" metadata:",optional"`
+	// ServiceRecordId is the service visit a service center remediated the
+	// recall in
+	ServiceRecordId string `json:"serviceRecordId,omitempty" metadata:",optional"`
+}
+
+// RecallCarStatus is the remediation state of one car of a campaign
+type RecallCarStatus struct {
+	CarId        string `json:"carId"`
+	Remediated   bool   `json:"remediated"`
+	RemediatedAt string `json:"remediatedAt,omitempty" metadata:",optional"`
+}
+
+// RecallStatus is the progress of a recall campaign
+type RecallStatus struct {
+	Recall            *Recall            `json:"recall"`
+	AffectedCount     int                `json:"affectedCount"`
+	RemediatedCount   int                `json:"remediatedCount"`
+	CompletionPercent float64            `json:"completionPercent"`
+	Cars              []*RecallCarStatus `json:"cars"`
+}
+
+// RecallEvent is the payload of the RecallIssued event
+type RecallEvent struct {
+	EventHeader
+	Recall *Recall `json:"recall"`
+}
+
+// IssueRecall declares a recall campaign for the cars of the given make and
+// model manufactured between manufacturedFrom and manufacturedTo, both
+// YYYY-MM-DD and inclusive. Every affected car that is not scrapped gets the
+// recall added to its record. blocksTransfers, which is only allowed on safety
+// recalls, stops the registration and resale of affected cars until they are
+// remediated.
+func (r *RecallContract) IssueRecall(ctx contractapi.TransactionContextInterface, recallID string, make string, model string, manufacturedFrom string, manufacturedTo string, description string, safety bool, blocksTransfers bool) (*Recall, error) {
+	if err := requireRole(ctx, RoleManufacturer); err != nil {
+		return nil, err
+	}
+
+	if recallID == "" || make == "" || model == "" {
+		return nil, fmt.Errorf("a recall ID, make and model are required")
+	}
+	if blocksTransfers && !safety {
+		return nil, fmt.Errorf("only safety recalls can block registrations and transfers")
+	}
+	existing, err := getRecall(ctx, recallID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("the recall %s already exists", recallID)
+	}
+
+	from, err := time.Parse("2006-01-02", strings.TrimSpace(manufacturedFrom))
+	if err != nil {
+		return nil, fmt.Errorf("manufacturedFrom %s is not a date, use YYYY-MM-DD", manufacturedFrom)
+	}
+	to, err := time.Parse("2006-01-02", strings.TrimSpace(manufacturedTo))
+	if err != nil {
+		return nil, fmt.Errorf("manufacturedTo %s is not a date, use YYYY-MM-DD", manufacturedTo)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("manufacturedTo %s is before manufacturedFrom %s", manufacturedTo, manufacturedFrom)
+	}
+
+	issuedBy, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	issuedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	recall := &Recall{
+		AssetType:        "recall",
+		RecallId:         recallID,
+		Make:             make,
+		Model:            model,
+		ManufacturedFrom: from.Format("2006-01-02"),
+		ManufacturedTo:   to.Format("2006-01-02"),
+		Description:      description,
+		Safety:           safety,
+		BlocksTransfers:  blocksTransfers,
+		IssuedBy:         issuedBy,
+		IssuedAt:         issuedAt,
+		AffectedCars:     []string{},
+	}
+
+	cars, err := getCarsByIndex(ctx, carAttributesIndex, []string{make, model})
+	if err != nil {
+		return nil, err
+	}
+	for _, car := range cars {
+		if car.Status == StatusScrapped {
+			continue
+		}
+		covered, dated := recallCovers(recall, car)
+		if !dated {
+			recall.UndatedCars = append(recall.UndatedCars, car.CarId)
+			continue
+		}
+		if !covered {
+			continue
+		}
+
+		car.Recalls = append(car.Recalls, &CarRecall{
+			RecallId:        recallID,
+			Safety:          safety,
+			BlocksTransfers: blocksTransfers,
+		})
+		err = putCarAnnotation(ctx, car, annotationRecalls)
+		if err != nil {
+			return nil, err
+		}
+		recall.AffectedCars = append(recall.AffectedCars, car.CarId)
+	}
+
+	err = putRecall(ctx, recall)
+	if err != nil {
+		return nil, err
+	}
+	header, err := newEventHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = setEvent(ctx, EventRecallIssued, RecallEvent{EventHeader: header, Recall: recall})
+	if err != nil {
+		return nil, err
+	}
+	return recall, nil
+}
+
+// MarkRemediated records that the recall work has been done on a car. It is
+// marked by the dealer holding the car, or by a service center that recorded
+// a visit of the car with AddServiceRecord since the recall was issued; the
+// remediation then refers to the latest such visit.
+func (r *RecallContract) MarkRemediated(ctx contractapi.TransactionContextInterface, recallID string, carID string) (*Car, error) {
+	if err := requireRole(ctx, RoleDealer, RoleServiceCenter); err != nil {
+		return nil, err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+	recall, err := readRecall(ctx, recallID)
+	if err != nil {
+		return nil, err
+	}
+	var carRecall *CarRecall
+	for _, recall := range car.Recalls {
+		if recall.RecallId == recallID {
+			carRecall = recall
+		}
+	}
+	if carRecall == nil {
+		return nil, fmt.Errorf("car %s is not affected by recall %s", carID, recallID)
+	}
+	if carRecall.Remediated {
+		return nil, fmt.Errorf("recall %s has already been remediated on car %s", recallID, carID)
+	}
+
+	remediatedBy, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	isDealer, err := hasRole(ctx, RoleDealer)
+	if err != nil {
+		return nil, err
+	}
+	var visit *ServiceRecord
+	if isDealer {
+		if car.Status != StatusAssignedToDealer || !isParty(remediatedBy, car.OwnedBy, car.OwnerMspId) {
+			return nil, fmt.Errorf("forbidden: only the dealer holding car %s or a service center that serviced it can mark recall %s remediated", carID, recallID)
+		}
+	} else {
+		visit, err = getLatestVisit(ctx, carID, remediatedBy, recall.IssuedAt)
+		if err != nil {
+			return nil, err
+		}
+		if visit == nil {
+			return nil, fmt.Errorf("forbidden: %s has recorded no service of car %s since recall %s was issued, record the visit with AddServiceRecord first", remediatedBy.Name, carID, recallID)
+		}
+	}
+	remediatedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	carRecall.Remediated = true
+	carRecall.RemediatedBy = remediatedBy
+	carRecall.RemediatedAt = remediatedAt
+	if visit != nil {
+		carRecall.ServiceRecordId = visit.RecordId
+	}
+
+	err = putCarAnnotation(ctx, car, annotationRecalls)
+	if err != nil {
+		return nil, err
+	}
+	err = emitCarEvent(ctx, EventRecallRemediated, car)
+	if err != nil {
+		return nil, err
+	}
+	return car, nil
+}
+
+// ReadRecall retrieves a recall campaign
+func (r *RecallContract) ReadRecall(ctx contractapi.TransactionContextInterface, recallID string) (*Recall, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+	return readRecall(ctx, recallID)
+}
+
+// GetAllRecalls retrieves all the recall campaigns
+func (r *RecallContract) GetAllRecalls(ctx contractapi.TransactionContextInterface) ([]*Recall, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+	return getRecalls(ctx)
+}
+
+// getRecalls reads all the recall campaigns
+func getRecalls(ctx contractapi.TransactionContextInterface) ([]*Recall, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(recallIndex, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	recalls := []*Recall{}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var recall Recall
+		err = json.Unmarshal(queryResult.Value, &recall)
+		if err != nil {
+			return nil, err
+		}
+		recalls = append(recalls, &recall)
+	}
+	return recalls, nil
+}
+
+// GetRecallStatus returns the remediation state of every car of a recall
+// campaign and the share of cars already remediated, in percent. Cars that
+// were scrapped or deleted since the campaign was issued no longer count.
+func (r *RecallContract) GetRecallStatus(ctx contractapi.TransactionContextInterface, recallID string) (*RecallStatus, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+
+	recall, err := readRecall(ctx, recallID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &RecallStatus{Recall: recall, Cars: []*RecallCarStatus{}}
+	for _, carID := range recall.AffectedCars {
+		exists, err := carExists(ctx, carID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		car, err := readCar(ctx, carID)
+		if err != nil {
+			return nil, err
+		}
+		if car.Status == StatusScrapped {
+			continue
+		}
+		for _, carRecall := range car.Recalls {
+			if carRecall.RecallId != recallID {
+				continue
+			}
+			status.Cars = append(status.Cars, &RecallCarStatus{
+				CarId:        carID,
+				Remediated:   carRecall.Remediated,
+				RemediatedAt: carRecall.RemediatedAt,
+			})
+			if carRecall.Remediated {
+				status.RemediatedCount++
+			}
+		}
+	}
+
+	status.AffectedCount = len(status.Cars)
+	status.CompletionPercent = 100
+	if status.AffectedCount > 0 {
+		percent := float64(status.RemediatedCount) * 100 / float64(status.AffectedCount)
+		status.CompletionPercent = math.Round(percent*100) / 100
+	}
+	return status, nil
+}
+
+// recallCovers returns whether the recall covers the car, and false for dated
+// when the date of manufacture of a car of the make and model cannot be read
+func recallCovers(recall *Recall, car *Car) (covered bool, dated bool) {
+	if car.Make != recall.Make || car.Model != recall.Model {
+		return false, true
+	}
+	manufactured, err := parseDate(car.DateOfManufacture)
+	if err != nil {
+		return false, false
+	}
+	from, _ := time.Parse("2006-01-02", recall.ManufacturedFrom)
+	to, _ := time.Parse("2006-01-02", recall.ManufacturedTo)
+	// compare the calendar day, ignoring any time stored with it
+	year, month, day := manufactured.Date()
+	manufacturedOn := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return !manufacturedOn.Before(from) && !manufacturedOn.After(to), true
+}
+
+// refreshRecalls brings the recalls of a car in line with its make, model and
+// date of manufacture once they are corrected, and returns whether they
+// changed. Open recalls that no longer cover the car are dropped and the
+// recalls that now cover it are added, and the campaigns are updated to
+// match. Remediated recalls are kept as a record of the work done.
+func refreshRecalls(ctx contractapi.TransactionContextInterface, car *Car) (bool, error) {
+	recalls, err := getRecalls(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	for _, recall := range recalls {
+		covered, dated := recallCovers(recall, car)
+		index := -1
+		for i, carRecall := range car.Recalls {
+			if carRecall.RecallId == recall.RecallId {
+				index = i
+			}
+		}
+
+		affected, undated := recall.AffectedCars, recall.UndatedCars
+		if covered && index < 0 {
+			car.Recalls = append(car.Recalls, &CarRecall{
+				RecallId:        recall.RecallId,
+				Safety:          recall.Safety,
+				BlocksTransfers: recall.BlocksTransfers,
+			})
+			recall.AffectedCars = appendMissing(recall.AffectedCars, car.CarId)
+			changed = true
+		}
+		if !covered && index >= 0 && !car.Recalls[index].Remediated {
+			car.Recalls = append(car.Recalls[:index], car.Recalls[index+1:]...)
+			recall.AffectedCars = removeString(recall.AffectedCars, car.CarId)
+			changed = true
+		}
+		if dated {
+			recall.UndatedCars = removeString(recall.UndatedCars, car.CarId)
+		} else {
+			recall.UndatedCars = appendMissing(recall.UndatedCars, car.CarId)
+		}
+
+		if len(affected) != len(recall.AffectedCars) || len(undated) != len(recall.UndatedCars) {
+			if err := putRecall(ctx, recall); err != nil {
+				return false, err
+			}
+		}
+	}
+	return changed, nil
+}
+
+// appendMissing appends the value to the list unless it holds it already
+func appendMissing(list []string, value string) []string {
+	for _, item := range list {
+		if item == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+// removeString returns the list without the value
+func removeString(list []string, value string) []string {
+	kept := []string{}
+	for _, item := range list {
+		if item != value {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// checkBlockingRecalls returns an error when the car has an open safety recall
+// that blocks its registration and transfer
+func checkBlockingRecalls(car *Car) error {
+	for _, recall := range car.Recalls {
+		if recall.BlocksTransfers && !recall.Remediated {
+			return fmt.Errorf("car %s has an open safety recall %s, it must be remediated first", car.CarId, recall.RecallId)
+		}
+	}
+	return nil
+}
+
+func readRecall(ctx contractapi.TransactionContextInterface, recallID string) (*Recall, error) {
+	recall, err := getRecall(ctx, recallID)
+	if err != nil {
+		return nil, err
+	}
+	if recall == nil {
+		return nil, fmt.Errorf("the recall %s does not exist", recallID)
+	}
+	return recall, nil
+}
+
+// getRecall returns the recall campaign, or nil when it does not exist
+func getRecall(ctx contractapi.TransactionContextInterface, recallID string) (*Recall, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(recallIndex, []string{recallID})
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if bytes == nil {
+		return nil, nil
+	}
+
+	var recall Recall
+	err = json.Unmarshal(bytes, &recall)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal world state data to type Recall")
+	}
+	return &recall, nil
+}
+
+func putRecall(ctx contractapi.TransactionContextInterface, recall *Recall) error {
+	key, err := ctx.GetStub().CreateCompositeKey(recallIndex, []string{recall.RecallId})
+	if err != nil {
+		return err
+	}
+	bytes, _ := json.Marshal(recall)
+	return ctx.GetStub().PutState(key, bytes)
+}
@@ -0,0 +1,83 @@
+package contracts
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// testServiceCenter is a service center of the dealers' org
+var testServiceCenter = testIdentity{"Org2MSP", RoleServiceCenter, "garage"}
+
+// readRecalls returns the recalls of a car as ReadCar shows them
+func readRecalls(l *testLedger, carID string) []*CarRecall {
+	l.t.Helper()
+	var car Car
+	if err := json.Unmarshal([]byte(l.mustInvoke(testManufacturer, nil, "CarContract:ReadCar", carID)), &car); err != nil {
+		l.t.Fatal(err)
+	}
+	return car.Recalls
+}
+
+func TestMarkRemediated(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke(testAdmin, nil, "AccessContract:SetRoleMSPs", RoleDealer, `["Org2MSP","Org4MSP"]`)
+	city := testIdentity{"Org4MSP", RoleDealer, "city"}
+	addDealer(l, testDealer)
+	addDealer(l, city)
+	deliverCar(l, testDealer, "C1")
+	deliverCar(l, testDealer, "C2")
+	sellCar(l, testDealer, testOwner("dani"), "C2", "KA02")
+	l.mustInvoke(testServiceCenter, nil, "ServiceContract:AddServiceRecord", "C2", "2025-01-09", "1000", "oil change", `[]`)
+	l.mustInvoke(testManufacturer, nil, "RecallContract:IssueRecall", "R1", "Maruti", "Alto", "2019-01-01", "2019-12-31", "brake pads", "true", "false")
+
+	l.mustFail(city, nil, "forbidden", "RecallContract:MarkRemediated", "R1", "C1")
+	l.mustFail(testDealer, nil, "forbidden", "RecallContract:MarkRemediated", "R1", "C2")
+	l.mustInvoke(testDealer, nil, "RecallContract:MarkRemediated", "R1", "C1")
+	l.mustFail(testDealer, nil, "already been remediated", "RecallContract:MarkRemediated", "R1", "C1")
+
+	// the visit before the recall does not count
+	l.mustFail(testServiceCenter, nil, "recorded no service", "RecallContract:MarkRemediated", "R1", "C2")
+	visit := l.mustInvoke(testServiceCenter, nil, "ServiceContract:AddServiceRecord", "C2", "2025-01-10", "1200", "brake pads replaced", `["brake pads"]`)
+	var record ServiceRecord
+	if err := json.Unmarshal([]byte(visit), &record); err != nil {
+		t.Fatal(err)
+	}
+	l.mustInvoke(testServiceCenter, nil, "RecallContract:MarkRemediated", "R1", "C2")
+	recalls := readRecalls(l, "C2")
+	if len(recalls) != 1 || !recalls[0].Remediated || recalls[0].ServiceRecordId != record.RecordId {
+		t.Fatalf("recalls of C2 = %+v, want R1 remediated in visit %s", recalls, record.RecordId)
+	}
+}
+
+func TestRecallsFollowCorrections(t *testing.T) {
+	l := newTestLedger(t)
+	l.mustInvoke(testManufacturer, nil, "CarContract:CreateCar", "C1", "Maruti", "Alto", "Red", "fac", "2018-05-01")
+	l.mustInvoke(testManufacturer, nil, "RecallContract:IssueRecall", "R1", "Maruti", "Alto", "2019-01-01", "2019-12-31", "brake pads", "true", "true")
+	if recalls := readRecalls(l, "C1"); len(recalls) != 0 {
+		t.Fatalf("C1 has %d recalls, want none", len(recalls))
+	}
+
+	l.mustInvoke(testManufacturer, nil, "CarContract:UpdateCar", "C1", `{"dateOfManufacture":"2019-06-01"}`, "typo in the date")
+	recalls := readRecalls(l, "C1")
+	if len(recalls) != 1 || recalls[0].RecallId != "R1" || !recalls[0].BlocksTransfers {
+		t.Fatalf("recalls of C1 = %+v, want R1 blocking transfers", recalls)
+	}
+	var recall Recall
+	if err := json.Unmarshal([]byte(l.mustInvoke(testManufacturer, nil, "RecallContract:ReadRecall", "R1")), &recall); err != nil {
+		t.Fatal(err)
+	}
+	if len(recall.AffectedCars) != 1 || recall.AffectedCars[0] != "C1" {
+		t.Fatalf("R1 affects %v, want C1", recall.AffectedCars)
+	}
+
+	l.mustInvoke(testManufacturer, nil, "CarContract:UpdateCar", "C1", `{"model":"Swift"}`, "wrong model")
+	if recalls := readRecalls(l, "C1"); len(recalls) != 0 {
+		t.Fatalf("C1 has %d recalls, want none", len(recalls))
+	}
+	if err := json.Unmarshal([]byte(l.mustInvoke(testManufacturer, nil, "RecallContract:ReadRecall", "R1")), &recall); err != nil {
+		t.Fatal(err)
+	}
+	if len(recall.AffectedCars) != 0 {
+		t.Fatalf("R1 affects %v, want no car", recall.AffectedCars)
+	}
+}
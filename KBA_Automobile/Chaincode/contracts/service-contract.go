@@ -0,0 +1,218 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// ServiceContract contract for managing the service records of cars
+type ServiceContract struct {
+	contractapi.Contract
+}
+
+// serviceRecordIndex is the composite key object type of service records.
+// Records are keyed service~carId~recordId, so the log of a car is a partial
+// key query on its carId.
+const serviceRecordIndex = "service~carId~recordId"
+
+// ServiceRecord is one visit of a car to a service center
+type ServiceRecord struct {
+	AssetType     string     `json:"assetType"`
+	RecordId      string     `json:"recordId"`
+	CarId         string     `json:"carId"`
+	ServiceDate   string     `json:"serviceDate"`
+	Odometer      int        `json:"odometer"`
+	WorkDone      string     `json:"workDone"`
+	PartsReplaced []string   `json:"partsReplaced"`
+	ServiceCenter *Submitter `json:"serviceCenter"`
+	RecordedAt    string     `json:"recordedAt"`
+}
+
+// ServiceRecordEvent is the payload of the ServiceRecorded event
+type ServiceRecordEvent struct {
+	EventHeader
+	Record *ServiceRecord `json:"record"`
+}
+
+// AddServiceRecord appends a service record to the maintenance log of a car.
+// The record ID is the ID of the transaction and the service center is the
+// submitting identity. The service date is given as YYYY-MM-DD. The odometer
+// value also goes into the odometer timeline of the car; when it is lower than
+// the last accepted reading, the OdometerRollbackSuspected event is raised
+// instead of ServiceRecorded.
+func (s *ServiceContract) AddServiceRecord(ctx contractapi.TransactionContextInterface, carID string, serviceDate string, odometer int, workDone string, partsReplaced []string) (*ServiceRecord, error) {
+	if err := requireRole(ctx, RoleServiceCenter); err != nil {
+		return nil, err
+	}
+
+	car, err := readCar(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+	if car.Status == StatusScrapped {
+		return nil, fmt.Errorf("car %s is %s and can no longer be serviced", carID, car.Status)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the transaction timestamp: %v", err)
+	}
+	date, err := time.Parse("2006-01-02", strings.TrimSpace(serviceDate))
+	if err != nil {
+		return nil, fmt.Errorf("service date %s is not a date, use YYYY-MM-DD", serviceDate)
+	}
+	if date.After(txTimestamp.AsTime()) {
+		return nil, fmt.Errorf("service date %s is in the future", serviceDate)
+	}
+	workDone = strings.TrimSpace(workDone)
+	if workDone == "" {
+		return nil, fmt.Errorf("the work done is required")
+	}
+
+	serviceCenter, err := getSubmitter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	recordedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if partsReplaced == nil {
+		partsReplaced = []string{}
+	}
+
+	record := &ServiceRecord{
+		AssetType:     "serviceRecord",
+		RecordId:      ctx.GetStub().GetTxID(),
+		CarId:         carID,
+		ServiceDate:   date.Format("2006-01-02"),
+		Odometer:      odometer,
+		WorkDone:      workDone,
+		PartsReplaced: partsReplaced,
+		ServiceCenter: serviceCenter,
+		RecordedAt:    recordedAt,
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(serviceRecordIndex, []string{carID, record.RecordId})
+	if err != nil {
+		return nil, err
+	}
+	bytes, _ := json.Marshal(record)
+	err = ctx.GetStub().PutState(key, bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rollback, err := recordOdometer(ctx, car, odometer, OdometerSourceService)
+	if err != nil {
+		return nil, err
+	}
+	err = putCarAnnotation(ctx, car, annotationOdometer)
+	if err != nil {
+		return nil, err
+	}
+	if rollback {
+		err = emitCarEvent(ctx, EventOdometerRollbackSuspected, car)
+		if err != nil {
+			return nil, err
+		}
+		return record, nil
+	}
+
+	header, err := newEventHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = setEvent(ctx, EventServiceRecorded, ServiceRecordEvent{EventHeader: header, Record: record})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// ReadServiceRecord retrieves one service record of a car
+func (s *ServiceContract) ReadServiceRecord(ctx contractapi.TransactionContextInterface, carID string, recordID string) (*ServiceRecord, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(serviceRecordIndex, []string{carID, recordID})
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if bytes == nil {
+		return nil, fmt.Errorf("the service record %s of car %s does not exist", recordID, carID)
+	}
+
+	var record ServiceRecord
+	err = json.Unmarshal(bytes, &record)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal world state data to type ServiceRecord")
+	}
+	return &record, nil
+}
+
+// GetServiceHistory returns the maintenance log of a car, oldest service first
+func (s *ServiceContract) GetServiceHistory(ctx contractapi.TransactionContextInterface, carID string) ([]*ServiceRecord, error) {
+	if err := requireRole(ctx, carReaders...); err != nil {
+		return nil, err
+	}
+	return getServiceRecords(ctx, carID)
+}
+
+// getServiceRecords reads the service records of a car, ordered by service
+// date and then by the time they were recorded
+func getServiceRecords(ctx contractapi.TransactionContextInterface, carID string) ([]*ServiceRecord, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(serviceRecordIndex, []string{carID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	records := []*ServiceRecord{}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var record ServiceRecord
+		err = json.Unmarshal(queryResult.Value, &record)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, &record)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].ServiceDate != records[j].ServiceDate {
+			return records[i].ServiceDate < records[j].ServiceDate
+		}
+		return records[i].RecordedAt < records[j].RecordedAt
+	})
+	return records, nil
+}
+
+// getLatestVisit returns the latest service record of the car the service
+// center recorded at or after the given RFC 3339 time, or nil
+func getLatestVisit(ctx contractapi.TransactionContextInterface, carID string, serviceCenter *Submitter, since string) (*ServiceRecord, error) {
+	records, err := getServiceRecords(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+	var latest *ServiceRecord
+	for _, record := range records {
+		if record.ServiceCenter != nil && *record.ServiceCenter == *serviceCenter && record.RecordedAt >= since {
+			latest = record
+		}
+	}
+	return latest, nil
+}
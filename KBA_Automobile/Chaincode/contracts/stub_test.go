@@ -0,0 +1,510 @@
+package contracts
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go-apiv2/msp"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testIdentity is the submitter of a test transaction
+type testIdentity struct {
+	mspID string
+	role  string
+	name  string
+}
+
+// Identities of the test network
+var (
+	testAdmin        = testIdentity{"Org1MSP", RoleAdmin, "admin"}
+	testManufacturer = testIdentity{"Org1MSP", RoleManufacturer, "factory"}
+	testDealer       = testIdentity{"Org2MSP", RoleDealer, "popular"}
+	testRTO          = testIdentity{"Org3MSP", RoleRTO, "rto"}
+)
+
+// testOwner returns an owner identity of the owners' org
+func testOwner(name string) testIdentity {
+	return testIdentity{"Org3MSP", RoleOwner, name}
+}
+
+// testLedger is an in-memory ledger the contracts are invoked against. Each
+// invocation is a transaction: its writes are only visible to the following
+// ones, and only when it succeeds.
+type testLedger struct {
+	t                    *testing.T
+	chaincode            *contractapi.ContractChaincode
+	state                map[string][]byte
+	private              map[string]map[string][]byte
+	history              map[string][]*queryresult.KeyModification
+	validationParameters map[string][]byte
+	// undeployed collections fail every read, as on a peer whose chaincode
+	// definition does not hold them
+	undeployed map[string]bool
+	purged     []string
+	events     []string
+	txCount    int
+	now        time.Time
+}
+
+func newTestLedger(t *testing.T) *testLedger {
+	t.Helper()
+	chaincode, err := contractapi.NewChaincode(new(CarContract), new(OrderContract), new(ServiceContract), new(RecallContract), new(AccessContract))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testLedger{
+		t:                    t,
+		chaincode:            chaincode,
+		state:                map[string][]byte{},
+		private:              map[string]map[string][]byte{},
+		history:              map[string][]*queryresult.KeyModification{},
+		validationParameters: map[string][]byte{},
+		undeployed:           map[string]bool{},
+		now:                  time.Date(2025, 1, 10, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+// invoke submits a transaction, e.g. "CarContract:ReadCar", and returns its
+// payload
+func (l *testLedger) invoke(caller testIdentity, transient map[string][]byte, args ...string) (string, error) {
+	l.txCount++
+	l.now = l.now.Add(time.Minute)
+	stub := &testStub{
+		ledger:    l,
+		args:      args,
+		creator:   testCreator(l.t, caller),
+		txID:      fmt.Sprintf("tx%03d", l.txCount),
+		timestamp: l.now,
+		transient: transient,
+		writes:    map[string][]byte{},
+		deletes:   map[string]bool{},
+		private:   map[string]map[string][]byte{},
+	}
+	response := l.chaincode.Invoke(stub)
+	if response.Status != shim.OK {
+		return "", fmt.Errorf("%s", response.Message)
+	}
+	stub.commit()
+	return string(response.Payload), nil
+}
+
+// mustInvoke submits a transaction that must succeed
+func (l *testLedger) mustInvoke(caller testIdentity, transient map[string][]byte, args ...string) string {
+	l.t.Helper()
+	payload, err := l.invoke(caller, transient, args...)
+	if err != nil {
+		l.t.Fatalf("%s: %v", strings.Join(args, " "), err)
+	}
+	return payload
+}
+
+// mustFail submits a transaction that must fail with an error containing want
+func (l *testLedger) mustFail(caller testIdentity, transient map[string][]byte, want string, args ...string) {
+	l.t.Helper()
+	_, err := l.invoke(caller, transient, args...)
+	if err == nil {
+		l.t.Fatalf("%s: succeeded, want an error containing %q", strings.Join(args, " "), want)
+	}
+	if !strings.Contains(err.Error(), want) {
+		l.t.Fatalf("%s: got error %q, want one containing %q", strings.Join(args, " "), err, want)
+	}
+}
+
+// lastEvent returns the name of the last event emitted
+func (l *testLedger) lastEvent() string {
+	if len(l.events) == 0 {
+		return ""
+	}
+	return l.events[len(l.events)-1]
+}
+
+// readCar reads a car from the world state, without its annotations
+func (l *testLedger) readCar(carID string) *Car {
+	l.t.Helper()
+	value, ok := l.state[carID]
+	if !ok {
+		l.t.Fatalf("car %s is not in the world state", carID)
+	}
+	var car Car
+	if err := json.Unmarshal(value, &car); err != nil {
+		l.t.Fatal(err)
+	}
+	return &car
+}
+
+// hasKey returns true when the world state holds the composite key
+func (l *testLedger) hasKey(objectType string, attributes ...string) bool {
+	key, err := shim.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	_, ok := l.state[key]
+	return ok
+}
+
+var testCreators = map[testIdentity][]byte{}
+
+// testCreator returns a serialized identity whose certificate carries the
+// role and enrollment ID attributes of the identity
+func testCreator(t *testing.T, caller testIdentity) []byte {
+	if creator, ok := testCreators[caller]; ok {
+		return creator
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs, _ := json.Marshal(map[string]interface{}{
+		"attrs": map[string]string{roleAttribute: caller.role, "hf.EnrollmentID": caller.name},
+	})
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: caller.name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		// the OID Fabric CA stores the attributes under
+		ExtraExtensions: []pkix.Extension{{Id: []int{1, 2, 3, 4, 5, 6, 7, 8, 1}, Value: attrs}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: caller.mspID, IdBytes: certificate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCreators[caller] = creator
+	return creator
+}
+
+// testStub is the stub of one transaction on a testLedger. Reads see the
+// ledger as it was before the transaction, as on a peer. Methods the contracts
+// do not use are left to the embedded interface and panic.
+type testStub struct {
+	shim.ChaincodeStubInterface
+	ledger    *testLedger
+	args      []string
+	creator   []byte
+	txID      string
+	timestamp time.Time
+	transient map[string][]byte
+	writes    map[string][]byte
+	deletes   map[string]bool
+	// private writes by collection, nil values are deletes
+	private map[string]map[string][]byte
+	event   string
+}
+
+// commit applies the writes of the transaction to the ledger
+func (s *testStub) commit() {
+	l := s.ledger
+	for key, value := range s.writes {
+		l.state[key] = value
+		l.history[key] = append(l.history[key], &queryresult.KeyModification{TxId: s.txID, Value: value, Timestamp: timestamppb.New(s.timestamp)})
+	}
+	for key := range s.deletes {
+		delete(l.state, key)
+		l.history[key] = append(l.history[key], &queryresult.KeyModification{TxId: s.txID, Timestamp: timestamppb.New(s.timestamp), IsDelete: true})
+	}
+	for collection, writes := range s.private {
+		if l.private[collection] == nil {
+			l.private[collection] = map[string][]byte{}
+		}
+		for key, value := range writes {
+			if value == nil {
+				delete(l.private[collection], key)
+			} else {
+				l.private[collection][key] = value
+			}
+		}
+	}
+	if s.event != "" {
+		l.events = append(l.events, s.event)
+	}
+}
+
+func (s *testStub) GetFunctionAndParameters() (string, []string) { return s.args[0], s.args[1:] }
+func (s *testStub) GetArgs() [][]byte {
+	args := make([][]byte, len(s.args))
+	for i, arg := range s.args {
+		args[i] = []byte(arg)
+	}
+	return args
+}
+func (s *testStub) GetCreator() ([]byte, error) { return s.creator, nil }
+func (s *testStub) GetTxID() string             { return s.txID }
+func (s *testStub) GetChannelID() string        { return "autochannel" }
+func (s *testStub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	return timestamppb.New(s.timestamp), nil
+}
+func (s *testStub) GetTransient() (map[string][]byte, error) { return s.transient, nil }
+
+func (s *testStub) SetEvent(name string, payload []byte) error {
+	s.event = name
+	return nil
+}
+
+func (s *testStub) GetState(key string) ([]byte, error) { return s.ledger.state[key], nil }
+
+func (s *testStub) PutState(key string, value []byte) error {
+	if key == "" {
+		return fmt.Errorf("key must not be an empty string")
+	}
+	s.writes[key] = value
+	delete(s.deletes, key)
+	return nil
+}
+
+func (s *testStub) DelState(key string) error {
+	s.deletes[key] = true
+	delete(s.writes, key)
+	return nil
+}
+
+func (s *testStub) SetStateValidationParameter(key string, ep []byte) error {
+	s.ledger.validationParameters[key] = ep
+	return nil
+}
+
+func (s *testStub) GetStateValidationParameter(key string) ([]byte, error) {
+	return s.ledger.validationParameters[key], nil
+}
+
+func (s *testStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+func (s *testStub) SplitCompositeKey(key string) (string, []string, error) {
+	parts := strings.Split(strings.TrimPrefix(key, "\x00"), "\x00")
+	return parts[0], parts[1 : len(parts)-1], nil
+}
+
+func (s *testStub) GetStateByRange(start, end string) (shim.StateQueryIteratorInterface, error) {
+	return &testIterator{kvs: simpleKeyRange(s.ledger.state, start, end)}, nil
+}
+
+func (s *testStub) GetStateByRangeWithPagination(start, end string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	kvs, metadata := testPage(simpleKeyRange(s.ledger.state, start, end), pageSize, bookmark)
+	return &testIterator{kvs: kvs}, metadata, nil
+}
+
+func (s *testStub) GetStateByPartialCompositeKey(objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	return &testIterator{kvs: compositeKeyRange(s.ledger.state, objectType, attributes)}, nil
+}
+
+func (s *testStub) GetStateByPartialCompositeKeyWithPagination(objectType string, attributes []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	kvs, metadata := testPage(compositeKeyRange(s.ledger.state, objectType, attributes), pageSize, bookmark)
+	return &testIterator{kvs: kvs}, metadata, nil
+}
+
+func (s *testStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	modifications := s.ledger.history[key]
+	newestFirst := make([]*queryresult.KeyModification, len(modifications))
+	for i, modification := range modifications {
+		newestFirst[len(modifications)-1-i] = modification
+	}
+	return &testHistoryIterator{modifications: newestFirst}, nil
+}
+
+func (s *testStub) collection(name string) (map[string][]byte, error) {
+	if s.ledger.undeployed[name] {
+		return nil, fmt.Errorf("collection %s could not be found", name)
+	}
+	return s.ledger.private[name], nil
+}
+
+func (s *testStub) GetPrivateData(collection, key string) ([]byte, error) {
+	values, err := s.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	return values[key], nil
+}
+
+func (s *testStub) GetPrivateDataHash(collection, key string) ([]byte, error) {
+	value, err := s.GetPrivateData(collection, key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(value)
+	return hash[:], nil
+}
+
+func (s *testStub) PutPrivateData(collection, key string, value []byte) error {
+	if _, err := s.collection(collection); err != nil {
+		return err
+	}
+	if s.private[collection] == nil {
+		s.private[collection] = map[string][]byte{}
+	}
+	s.private[collection][key] = value
+	return nil
+}
+
+func (s *testStub) DelPrivateData(collection, key string) error {
+	if _, err := s.collection(collection); err != nil {
+		return err
+	}
+	if s.private[collection] == nil {
+		s.private[collection] = map[string][]byte{}
+	}
+	s.private[collection][key] = nil
+	return nil
+}
+
+func (s *testStub) PurgePrivateData(collection, key string) error {
+	if err := s.DelPrivateData(collection, key); err != nil {
+		return err
+	}
+	s.ledger.purged = append(s.ledger.purged, collection+"/"+key)
+	return nil
+}
+
+func (s *testStub) GetPrivateDataByRange(collection, start, end string) (shim.StateQueryIteratorInterface, error) {
+	values, err := s.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	return &testIterator{kvs: simpleKeyRange(values, start, end)}, nil
+}
+
+func (s *testStub) GetPrivateDataByPartialCompositeKey(collection, objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	values, err := s.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	return &testIterator{kvs: compositeKeyRange(values, objectType, attributes)}, nil
+}
+
+// GetPrivateDataQueryResult only supports selectors of plain field values
+func (s *testStub) GetPrivateDataQueryResult(collection, query string) (shim.StateQueryIteratorInterface, error) {
+	values, err := s.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	var parsed struct {
+		Selector map[string]interface{} `json:"selector"`
+	}
+	if err := json.Unmarshal([]byte(query), &parsed); err != nil {
+		return nil, err
+	}
+	var kvs []*queryresult.KV
+	for _, kv := range simpleKeyRange(values, "", "") {
+		var document map[string]interface{}
+		if json.Unmarshal(kv.Value, &document) != nil {
+			continue
+		}
+		matches := true
+		for field, want := range parsed.Selector {
+			if document[field] != want {
+				matches = false
+			}
+		}
+		if matches {
+			kvs = append(kvs, kv)
+		}
+	}
+	return &testIterator{kvs: kvs}, nil
+}
+
+// simpleKeyRange returns the simple keys in [start, end), sorted. An empty
+// end is the end of the key space and composite keys are left out, as on a
+// peer.
+func simpleKeyRange(values map[string][]byte, start, end string) []*queryresult.KV {
+	var kvs []*queryresult.KV
+	for key, value := range values {
+		if strings.HasPrefix(key, "\x00") || key < start || (end != "" && key >= end) {
+			continue
+		}
+		kvs = append(kvs, &queryresult.KV{Key: key, Value: value})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs
+}
+
+// compositeKeyRange returns the composite keys starting with the given
+// attributes, sorted
+func compositeKeyRange(values map[string][]byte, objectType string, attributes []string) []*queryresult.KV {
+	prefix, _ := shim.CreateCompositeKey(objectType, attributes)
+	var kvs []*queryresult.KV
+	for key, value := range values {
+		if strings.HasPrefix(key, prefix) {
+			kvs = append(kvs, &queryresult.KV{Key: key, Value: value})
+		}
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs
+}
+
+// testPage returns the page of the keys starting at the bookmark, which is
+// the first key of the page
+func testPage(kvs []*queryresult.KV, pageSize int32, bookmark string) ([]*queryresult.KV, *peer.QueryResponseMetadata) {
+	start := sort.Search(len(kvs), func(i int) bool { return kvs[i].Key >= bookmark })
+	end := start + int(pageSize)
+	if end > len(kvs) {
+		end = len(kvs)
+	}
+	next := ""
+	if end < len(kvs) {
+		next = kvs[end].Key
+	}
+	return kvs[start:end], &peer.QueryResponseMetadata{FetchedRecordsCount: int32(end - start), Bookmark: next}
+}
+
+type testIterator struct {
+	kvs []*queryresult.KV
+	i   int
+}
+
+func (it *testIterator) HasNext() bool { return it.i < len(it.kvs) }
+func (it *testIterator) Close() error  { return nil }
+func (it *testIterator) Next() (*queryresult.KV, error) {
+	it.i++
+	return it.kvs[it.i-1], nil
+}
+
+type testHistoryIterator struct {
+	modifications []*queryresult.KeyModification
+	i             int
+}
+
+func (it *testHistoryIterator) HasNext() bool { return it.i < len(it.modifications) }
+func (it *testHistoryIterator) Close() error  { return nil }
+func (it *testHistoryIterator) Next() (*queryresult.KeyModification, error) {
+	it.i++
+	return it.modifications[it.i-1], nil
+}
+
+// placeOrder places an order of one car
+func placeOrder(l *testLedger, dealer testIdentity, orderID string, make string, model string, color string) {
+	l.t.Helper()
+	transient := map[string][]byte{"make": []byte(make), "model": []byte(model), "color": []byte(color)}
+	l.mustInvoke(dealer, transient, "OrderContract:CreateOrder", orderID)
+}
+
+// deliverCar builds a car and assigns it to the dealer through an order
+func deliverCar(l *testLedger, dealer testIdentity, carID string) {
+	l.t.Helper()
+	l.mustInvoke(testManufacturer, nil, "CarContract:CreateCar", carID, "Maruti", "Alto", "Red", "fac", "2019-10-25")
+	placeOrder(l, dealer, "O-"+carID, "Maruti", "Alto", "Red")
+	l.mustInvoke(testManufacturer, nil, "CarContract:MatchOrder", carID, "O-"+carID)
+}
@@ -0,0 +1,118 @@
+package contracts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// vinLength is the length of an ISO 3779 vehicle identification number
+const vinLength = 17
+
+// vinWeights are the position weights used to compute the VIN check digit
+var vinWeights = [vinLength]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+// vinModelYearCodes is the order of the model year codes at position 10. The
+// codes repeat every 30 years, starting with A for 1980 and 2010.
+const vinModelYearCodes = "ABCDEFGHJKLMNPRSTVWXY123456789"
+
+// vinValue returns the value a VIN character counts for in the check digit
+func vinValue(r rune) (int, bool) {
+	switch {
+	case r >= '0' && r <= '9':
+		return int(r - '0'), true
+	case r == 'I' || r == 'O' || r == 'Q':
+		return 0, false
+	case r >= 'A' && r <= 'H':
+		return int(r-'A') + 1, true
+	case r >= 'J' && r <= 'R':
+		return int(r-'J') + 1, true
+	case r >= 'S' && r <= 'Z':
+		return int(r-'S') + 2, true
+	}
+	return 0, false
+}
+
+// validateVIN checks the characters and the check digit of a VIN
+func validateVIN(vin string) error {
+	if len(vin) != vinLength {
+		return fmt.Errorf("VIN %s must be %d characters long", vin, vinLength)
+	}
+
+	sum := 0
+	for i, r := range vin {
+		value, ok := vinValue(r)
+		if !ok {
+			return fmt.Errorf("VIN %s contains %q at position %d, only digits and upper case letters other than I, O and Q are allowed", vin, r, i+1)
+		}
+		sum += value * vinWeights[i]
+	}
+
+	check := "0123456789X"[sum%11]
+	if vin[8] != check {
+		return fmt.Errorf("VIN %s has check digit %c, expected %c", vin, vin[8], check)
+	}
+	return nil
+}
+
+// decodeVIN returns the world manufacturer identifier and the model year of a
+// valid VIN. The model year code repeats every 30 years; the latest year that
+// is not more than one year after the date of manufacture is chosen.
+func decodeVIN(vin string, manufactured time.Time) (string, int) {
+	wmi := vin[:3]
+
+	index := strings.IndexByte(vinModelYearCodes, vin[9])
+	if index < 0 {
+		return wmi, 0
+	}
+	year := 1980 + index
+	for year+30 <= manufactured.Year()+1 {
+		year += 30
+	}
+	return wmi, year
+}
+
+// vinFromTransient reads the optional transient field vin of CreateCar
+func vinFromTransient(ctx contractapi.TransactionContextInterface) (string, error) {
+	transientData, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(transientData["vin"])), nil
+}
+
+// manufactureDateLayouts are the accepted input formats for the date of manufacture
+var manufactureDateLayouts = []string{
+	"2006-01-02",
+	time.RFC3339,
+	"02/01/2006",
+	"02-01-2006",
+}
+
+// parseManufactureDate parses the date of manufacture, rejecting dates after
+// the transaction timestamp
+func parseManufactureDate(value string, now time.Time) (time.Time, error) {
+	date, err := parseDate(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if date.After(now) {
+		return time.Time{}, fmt.Errorf("date of manufacture %s is in the future", value)
+	}
+	return date, nil
+}
+
+// parseDate parses a date of manufacture in any of the accepted formats. Cars
+// store it as YYYY-MM-DD, but cars created by earlier versions of this
+// chaincode may hold the other formats.
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range manufactureDateLayouts {
+		date, err := time.Parse(layout, strings.TrimSpace(value))
+		if err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date of manufacture %s is not a date, use YYYY-MM-DD", value)
+}
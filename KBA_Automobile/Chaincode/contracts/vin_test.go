@@ -0,0 +1,131 @@
+package contracts
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateVIN(t *testing.T) {
+	tests := []struct {
+		name string
+		vin  string
+		err  string
+	}{
+		{"check digit X", "1M8GDM9AXKP042788", ""},
+		{"check digit 3", "1HGCM82633A004352", ""},
+		{"all ones", "11111111111111111", ""},
+		{"wrong check digit", "1M8GDM9A1KP042788", "has check digit 1, expected X"},
+		{"letter O", "1M8GDM9AXKP0427O8", `contains 'O' at position 16`},
+		{"lower case", "1m8GDM9AXKP042788", `contains 'm' at position 2`},
+		{"too short", "1M8GDM9AXKP04278", "must be 17 characters long"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateVIN(tt.vin)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("validateVIN(%s) = %v, want no error", tt.vin, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("validateVIN(%s) = %v, want an error containing %q", tt.vin, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDecodeVIN(t *testing.T) {
+	tests := []struct {
+		name         string
+		vin          string
+		manufactured string
+		wmi          string
+		year         int
+	}{
+		{"first cycle", "1M8GDM9AXKP042788", "1989-06-01", "1M8", 1989},
+		{"second cycle", "1M8GDM9AXKP042788", "2019-06-01", "1M8", 2019},
+		{"next model year built the year before", "1M8GDM9AXKP042788", "2018-09-01", "1M8", 2019},
+		{"two years early stays in the earlier cycle", "1M8GDM9AXKP042788", "2017-09-01", "1M8", 1989},
+		{"digit codes of the first cycle", "1HGCM82633A004352", "2003-03-01", "1HG", 2003},
+		{"digit codes of the second cycle", "1HGCM82633A004352", "2033-03-01", "1HG", 2033},
+		{"last code of a cycle", "11111111191111111", "2039-01-01", "111", 2039},
+		{"unknown year code", "11111111101111111", "2020-01-01", "111", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manufactured, err := time.Parse("2006-01-02", tt.manufactured)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wmi, year := decodeVIN(tt.vin, manufactured)
+			if wmi != tt.wmi || year != tt.year {
+				t.Fatalf("decodeVIN(%s, %s) = %s, %d, want %s, %d", tt.vin, tt.manufactured, wmi, year, tt.wmi, tt.year)
+			}
+		})
+	}
+}
+
+func TestParseManufactureDate(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  string
+		err   string
+	}{
+		{"ISO date", "2024-01-31", "2024-01-31", ""},
+		{"RFC 3339", "2024-01-31T08:30:00Z", "2024-01-31", ""},
+		{"day first with slashes", "31/01/2024", "2024-01-31", ""},
+		{"day first with dashes", "31-01-2024", "2024-01-31", ""},
+		{"surrounding spaces", " 2024-01-31 ", "2024-01-31", ""},
+		{"the transaction day", "2024-06-15", "2024-06-15", ""},
+		{"after the transaction", "2024-06-16", "", "is in the future"},
+		{"later the same day", "2024-06-15T13:00:00Z", "", "is in the future"},
+		{"month first", "01/31/2024", "", "is not a date"},
+		{"no such day", "2024-02-30", "", "is not a date"},
+		{"empty", "", "", "is not a date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, err := parseManufactureDate(tt.value, now)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseManufactureDate(%q) = %v, %v, want an error containing %q", tt.value, date, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseManufactureDate(%q) = %v", tt.value, err)
+			}
+			if got := date.Format("2006-01-02"); got != tt.want {
+				t.Fatalf("parseManufactureDate(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateCarVIN(t *testing.T) {
+	l := newTestLedger(t)
+
+	// a 17 character car ID is not taken for a VIN
+	l.mustInvoke(testManufacturer, nil, "CarContract:CreateCar", "CAR-2019-00000001", "Maruti", "Alto", "Red", "fac", "2019-10-25")
+	if car := l.readCar("CAR-2019-00000001"); car.Vin != "" || car.ManufacturerCode != "" || car.ModelYear != 0 {
+		t.Fatalf("car CAR-2019-00000001 = vin %q, %s, %d, want no VIN", car.Vin, car.ManufacturerCode, car.ModelYear)
+	}
+
+	vin := map[string][]byte{"vin": []byte("1M8GDM9AXKP042788")}
+	l.mustInvoke(testManufacturer, vin, "CarContract:CreateCar", "C1", "Maruti", "Alto", "Red", "fac", "2019-10-25")
+	if car := l.readCar("C1"); car.Vin != "1M8GDM9AXKP042788" || car.ManufacturerCode != "1M8" || car.ModelYear != 2019 {
+		t.Fatalf("car C1 = vin %q, %s, %d, want 1M8GDM9AXKP042788, 1M8, 2019", car.Vin, car.ManufacturerCode, car.ModelYear)
+	}
+	l.mustFail(testManufacturer, map[string][]byte{"vin": []byte("1M8GDM9A1KP042788")}, "has check digit 1", "CarContract:CreateCar", "C2", "Maruti", "Alto", "Red", "fac", "2019-10-25")
+	l.mustFail(testManufacturer, nil, "has check digit 1", "CarContract:CreateCarsBatch", `[{"carId":"C3","vin":"1M8GDM9A1KP042788","make":"Maruti","model":"Alto","color":"Red","manufacturerName":"fac","dateOfManufacture":"2019-10-25"}]`)
+
+	// a corrected date of manufacture decodes the model year again
+	l.mustInvoke(testManufacturer, nil, "CarContract:UpdateCar", "C1", `{"dateOfManufacture":"1989-06-01"}`, "typo")
+	if car := l.readCar("C1"); car.ModelYear != 1989 {
+		t.Fatalf("model year of C1 = %d, want 1989", car.ModelYear)
+	}
+}
@@ -10,14 +10,17 @@ import (
 func main() {
 	carContract := new(contracts.CarContract)
 	orderContract := new(contracts.OrderContract)
+	serviceContract := new(contracts.ServiceContract)
+	recallContract := new(contracts.RecallContract)
+	accessContract := new(contracts.AccessContract)
 
-	chaincode, err := contractapi.NewChaincode(carContract,orderContract)
+	chaincode, err := contractapi.NewChaincode(carContract, orderContract, serviceContract, recallContract, accessContract)
 
 	if err != nil {
 		log.Panicf("Could not create chaincode : %v", err)
 	}
 
-	err= chaincode.Start()
+	err = chaincode.Start()
 
 	if err != nil {
 		log.Panicf("Failed to start chaincode : %v", err)
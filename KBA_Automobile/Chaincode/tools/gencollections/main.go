@@ -0,0 +1,133 @@
+// Command gencollections adds the order collections of the registered dealers
+// to the collections config of the chaincode. The dealer list is the JSON
+// returned by OrderContract:GetDealers, e.g.
+//
+//	peer chaincode query -C autochannel -n KBA-Automobile -c '{"Args":["OrderContract:GetDealers"]}' > dealers.json
+//	go run ./tools/gencollections -dealers dealers.json
+//
+// Fabric cannot remove a collection from a chaincode definition, nor change its
+// blockToLive, so the collections already in the config are kept as they are
+// and only the collections of new dealers are added. They copy the peer counts,
+// blockToLive and memberOnlyRead of OrderCollection. Once the definition with
+// the new collections is committed, activate their dealers with
+// OrderContract:ActivateDealer.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"kbaauto/contracts"
+	"log"
+	"os"
+)
+
+// collection is the part of a collection config entry this command writes or
+// needs to read. Existing entries are written back unchanged.
+type collection struct {
+	Name              string `json:"name"`
+	Policy            string `json:"policy"`
+	RequiredPeerCount int    `json:"requiredPeerCount"`
+	MaxPeerCount      int    `json:"maxPeerCount"`
+	BlockToLive       uint64 `json:"blockToLive"`
+	MemberOnlyRead    bool   `json:"memberOnlyRead"`
+}
+
+func main() {
+	dealersPath := flag.String("dealers", "", "the dealer list returned by OrderContract:GetDealers")
+	configPath := flag.String("config", "collections.json", "the collections config to update")
+	outPath := flag.String("o", "", "where to write the updated config, the -config file by default")
+	manufacturerMSP := flag.String("manufacturer", "Org1MSP", "the MSP ID of the manufacturer's org")
+	flag.Parse()
+
+	if *dealersPath == "" {
+		log.Fatal("the dealer list is required, see -dealers")
+	}
+	if *outPath == "" {
+		*outPath = *configPath
+	}
+
+	var dealers []*contracts.Dealer
+	if err := readJSON(*dealersPath, &dealers); err != nil {
+		log.Fatal(err)
+	}
+	var entries []json.RawMessage
+	if err := readJSON(*configPath, &entries); err != nil {
+		log.Fatal(err)
+	}
+
+	config, added, err := addDealerCollections(entries, dealers, *manufacturerMSP)
+	if err != nil {
+		log.Fatal(err)
+	}
+	out, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*outPath, append(out, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("added %d dealer collections to %s", added, *outPath)
+}
+
+// addDealerCollections appends a collection for every dealer that has none in
+// the config yet and returns the new config with the number of collections
+// added
+func addDealerCollections(entries []json.RawMessage, dealers []*contracts.Dealer, manufacturerMSP string) ([]json.RawMessage, int, error) {
+	template := collection{RequiredPeerCount: 1, MaxPeerCount: 2, MemberOnlyRead: true}
+	existing := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		var c collection
+		if err := json.Unmarshal(entry, &c); err != nil {
+			return nil, 0, fmt.Errorf("invalid collection in the config: %v", err)
+		}
+		existing[c.Name] = true
+		if c.Name == contracts.OrderCollection {
+			template = c
+		}
+	}
+
+	// every dealer needs an org of its own, or the peers of that org would
+	// hold the orders of all its dealers
+	dealerOf := map[string]string{manufacturerMSP: "the manufacturer"}
+	for _, dealer := range dealers {
+		if dealer.MspId == "" {
+			return nil, 0, fmt.Errorf("dealer %s has no MSP ID", dealer.Name)
+		}
+		if other, taken := dealerOf[dealer.MspId]; taken {
+			return nil, 0, fmt.Errorf("dealer %s shares %s with %s, a dealer needs an org of its own", dealer.Name, dealer.MspId, other)
+		}
+		dealerOf[dealer.MspId] = "dealer " + dealer.Name
+	}
+
+	added := 0
+	for _, dealer := range dealers {
+		name := contracts.DealerCollection(dealer.Name)
+		if existing[name] {
+			continue
+		}
+
+		c := template
+		c.Name = name
+		c.Policy = fmt.Sprintf("OR('%s.member', '%s.member')", manufacturerMSP, dealer.MspId)
+		entry, err := json.Marshal(c)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+		existing[name] = true
+		added++
+	}
+	return entries, added, nil
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid JSON in %s: %v", path, err)
+	}
+	return nil
+}
@@ -36,6 +36,16 @@ func main() {
 	// result := submitTxnFn("org1", "autochannel", "KBA-Automobile", "OrderContract", "query", make(map[string][]byte), "GetAllOrders")
 	// result := submitTxnFn("org1", "autochannel", "KBA-Automobile", "CarContract", "query", make(map[string][]byte), "GetMatchingOrders", "Car-06")
 	// result := submitTxnFn("org1", "autochannel", "KBA-Automobile", "CarContract", "invoke", make(map[string][]byte), "MatchOrder", "Car-06", "ORD-05")
-	result := submitTxnFn("org3", "autochannel", "KBA-Automobile", "CarContract", "invoke", make(map[string][]byte), "RegisterCar", "Car-06", "Dani", "KL-01-CD-01")
+	// result := submitTxnFn("org2", "autochannel", "KBA-Automobile", "CarContract", "invoke", make(map[string][]byte), "ProposeTransfer", "Car-06", "Dani", "Org3MSP")
+	// result := submitTxnFn("org3", "autochannel", "KBA-Automobile", "CarContract", "invoke", make(map[string][]byte), "AcceptTransfer", "Car-06")
+
+	// salePrice := map[string][]byte{
+	// 	"price":   []byte("500000"),
+	// 	"tradeId": []byte("Car-06-Dani"),
+	// }
+
+	// result := submitTxnFn("org2", "autochannel", "KBA-Automobile", "CarContract", "private", salePrice, "AgreeSalePrice", "Car-06")
+	// result := submitTxnFn("org3", "autochannel", "KBA-Automobile", "CarContract", "private", salePrice, "AgreeSalePrice", "Car-06")
+	result := submitTxnFn("org3", "autochannel", "KBA-Automobile", "CarContract", "invoke", make(map[string][]byte), "RegisterCar", "Car-06", "Dani", "Org3MSP", "KL-01-CD-01")
 	fmt.Println(result)
 }
@@ -0,0 +1,218 @@
+// Package couchquery builds CouchDB rich queries for chaincode. Selectors are
+// assembled from field names, operators and values and marshalled with
+// encoding/json, so a value can never change the structure of the query.
+// Field names and operators are checked against an allow list.
+//
+// The package has no dependencies so that every chaincode in this repository
+// can use it. The peer only sees the chaincode directory, so each chaincode
+// carries a copy: the automobile chaincode vendors it through a replace
+// directive in its go.mod, the rice chaincode keeps one in rice/couchquery.
+// Run ../scripts/check-couchquery-copies.sh after changing it.
+package couchquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// Operators supported in conditions. Combination and regular expression
+// operators such as $or, $not and $regex are deliberately left out.
+const (
+	Eq     = "$eq"
+	Ne     = "$ne"
+	Gt     = "$gt"
+	Gte    = "$gte"
+	Lt     = "$lt"
+	Lte    = "$lte"
+	In     = "$in"
+	Nin    = "$nin"
+	Exists = "$exists"
+)
+
+var operators = map[string]bool{Eq: true, Ne: true, Gt: true, Gte: true, Lt: true, Lte: true, In: true, Nin: true, Exists: true}
+
+// fieldPattern matches the field names allowed in selectors, sort and fields.
+// Names may be nested with dots but may not start with $.
+var fieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// MaxLimit is the largest limit a query may ask for
+const MaxLimit = 1000
+
+// Builder collects the parts of a query. The first error is kept and returned
+// by Build, so calls can be chained.
+type Builder struct {
+	selector map[string]map[string]interface{}
+	sort     []map[string]string
+	fields   []string
+	limit    int
+	err      error
+}
+
+// New returns an empty query builder
+func New() *Builder {
+	return &Builder{selector: map[string]map[string]interface{}{}}
+}
+
+// Eq adds the condition field == value
+func (b *Builder) Eq(field string, value interface{}) *Builder {
+	return b.Where(field, Eq, value)
+}
+
+// Where adds the condition field <operator> value. Several conditions on the
+// same field are combined; a second condition with the same operator on a
+// field is an error.
+func (b *Builder) Where(field string, operator string, value interface{}) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if err := checkField(field); err != nil {
+		b.err = err
+		return b
+	}
+	if !operators[operator] {
+		b.err = fmt.Errorf("unsupported query operator %q", operator)
+		return b
+	}
+	if err := checkValue(operator, value); err != nil {
+		b.err = fmt.Errorf("invalid value for %s %s: %v", field, operator, err)
+		return b
+	}
+
+	conditions, ok := b.selector[field]
+	if !ok {
+		conditions = map[string]interface{}{}
+		b.selector[field] = conditions
+	}
+	if _, ok := conditions[operator]; ok {
+		b.err = fmt.Errorf("the condition %s %s is given twice", field, operator)
+		return b
+	}
+	conditions[operator] = value
+	return b
+}
+
+// Sort orders the results by the field, ascending or descending. CouchDB can
+// only sort on fields covered by an index.
+func (b *Builder) Sort(field string, descending bool) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if err := checkField(field); err != nil {
+		b.err = err
+		return b
+	}
+	direction := "asc"
+	if descending {
+		direction = "desc"
+	}
+	b.sort = append(b.sort, map[string]string{field: direction})
+	return b
+}
+
+// Fields restricts the returned documents to the given fields
+func (b *Builder) Fields(fields ...string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	for _, field := range fields {
+		if err := checkField(field); err != nil {
+			b.err = err
+			return b
+		}
+	}
+	b.fields = append(b.fields, fields...)
+	return b
+}
+
+// Limit caps the number of results. Fabric rejects a limit in paginated
+// queries, use the page size there instead.
+func (b *Builder) Limit(limit int) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if limit < 1 || limit > MaxLimit {
+		b.err = fmt.Errorf("limit must be between 1 and %d, got %d", MaxLimit, limit)
+		return b
+	}
+	b.limit = limit
+	return b
+}
+
+// Build returns the query as JSON text, ready for GetQueryResult
+func (b *Builder) Build() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+	if len(b.selector) == 0 {
+		return "", fmt.Errorf("a query needs at least one condition")
+	}
+
+	query := struct {
+		Selector map[string]map[string]interface{} `json:"selector"`
+		Sort     []map[string]string               `json:"sort,omitempty"`
+		Fields   []string                          `json:"fields,omitempty"`
+		Limit    int                               `json:"limit,omitempty"`
+	}{b.selector, b.sort, b.fields, b.limit}
+
+	bytes, err := json.Marshal(query)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the query: %v", err)
+	}
+	return string(bytes), nil
+}
+
+// Has returns true when the selector has a condition on the field
+func (b *Builder) Has(field string) bool {
+	_, ok := b.selector[field]
+	return ok
+}
+
+func checkField(field string) error {
+	if !fieldPattern.MatchString(field) {
+		return fmt.Errorf("invalid query field %q", field)
+	}
+	return nil
+}
+
+// checkValue only lets scalar values through, and lists of scalars for $in
+// and $nin. Objects could smuggle operators into the selector.
+func checkValue(operator string, value interface{}) error {
+	switch operator {
+	case In, Nin:
+		values, ok := value.([]interface{})
+		if !ok {
+			if strings, ok := value.([]string); ok {
+				values = make([]interface{}, len(strings))
+				for i, s := range strings {
+					values[i] = s
+				}
+			} else {
+				return fmt.Errorf("a list is required")
+			}
+		}
+		for _, v := range values {
+			if !isScalar(v) {
+				return fmt.Errorf("list entries must be strings, numbers or booleans")
+			}
+		}
+		return nil
+	case Exists:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("true or false is required")
+		}
+		return nil
+	}
+	if !isScalar(value) {
+		return fmt.Errorf("a string, number or boolean is required")
+	}
+	return nil
+}
+
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, bool, int, int32, int64, float32, float64, json.Number:
+		return true
+	}
+	return false
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strconv"
 
+	"rice/couchquery"
+
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -131,7 +133,10 @@ func (s *SmartContract) ReadPrivateDetails(ctx contractapi.TransactionContextInt
 
 // 📊 Rich Query by Location
 func (s *SmartContract) QueryByLocation(ctx contractapi.TransactionContextInterface, location string) ([]*RiceBatch, error) {
-	query := fmt.Sprintf(`{"selector":{"location":"%s"}}`, location)
+	query, err := couchquery.New().Eq("location", location).Build()
+	if err != nil {
+		return nil, err
+	}
 	resultsIterator, err := ctx.GetStub().GetQueryResult(query)
 	if err != nil {
 		return nil, err
@@ -153,7 +158,10 @@ func (s *SmartContract) QueryByLocation(ctx contractapi.TransactionContextInterf
 
 // 🔍 Query by QualityGrade
 func (s *SmartContract) QueryByQuality(ctx contractapi.TransactionContextInterface, quality string) ([]*RiceBatch, error) {
-	query := fmt.Sprintf(`{"selector":{"qualityGrade":"%s"}}`, quality)
+	query, err := couchquery.New().Eq("qualityGrade", quality).Build()
+	if err != nil {
+		return nil, err
+	}
 	resultsIterator, err := ctx.GetStub().GetQueryResult(query)
 	if err != nil {
 		return nil, err
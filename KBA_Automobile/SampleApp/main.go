@@ -1,12 +1,20 @@
 package main
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// defaultPageSize is used by the listing endpoints when no pageSize is given
+const defaultPageSize = "20"
+
 type Car struct {
 	CarId        string `json:"carId"`
 	Make         string `json:"make"`
@@ -45,5 +53,192 @@ func main() {
 		ctx.JSON(http.StatusOK, gin.H{"data": result})
 	})
 
+	router.GET("/api/car/:id/asof", func(ctx *gin.Context) {
+		carId := ctx.Param("id")
+		timestamp := ctx.Query("timestamp")
+		if timestamp == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "timestamp is required, e.g. 2024-01-31T00:00:00Z"})
+			return
+		}
+		result := submitTxnFn("org1", "autochannel", "KBA-Automobile", "CarContract", "query", make(map[string][]byte), "GetCarAsOf", carId, timestamp)
+
+		ctx.JSON(http.StatusOK, gin.H{"data": result})
+	})
+
+	router.GET("/api/cars/registration/:number", func(ctx *gin.Context) {
+		number := ctx.Param("number")
+		result := submitTxnFn("org1", "autochannel", "KBA-Automobile", "CarContract", "query", make(map[string][]byte), "GetCarByRegistration", number)
+
+		ctx.JSON(http.StatusOK, gin.H{"data": result})
+	})
+
+	router.GET("/api/cars", func(ctx *gin.Context) {
+		pageSize, bookmark, ok := pageParams(ctx)
+		if !ok {
+			return
+		}
+		result := submitTxnFn("org1", "autochannel", "KBA-Automobile", "CarContract", "query", make(map[string][]byte), "GetAllCarsWithPagination", pageSize, bookmark)
+
+		ctx.JSON(http.StatusOK, gin.H{"data": result})
+	})
+
+	router.GET("/api/cars/range", func(ctx *gin.Context) {
+		pageSize, bookmark, ok := pageParams(ctx)
+		if !ok {
+			return
+		}
+		result := submitTxnFn("org1", "autochannel", "KBA-Automobile", "CarContract", "query", make(map[string][]byte), "GetCarsByRangeWithPagination", ctx.Query("startKey"), ctx.Query("endKey"), pageSize, bookmark)
+
+		ctx.JSON(http.StatusOK, gin.H{"data": result})
+	})
+
+	router.POST("/api/cars/batch", func(ctx *gin.Context) {
+		var req []Car
+		if err := ctx.BindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "Bad request"})
+			return
+		}
+		createCarsBatch(ctx, req)
+	})
+
+	router.POST("/api/cars/batch/csv", func(ctx *gin.Context) {
+		file, err := ctx.FormFile("file")
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "a CSV file is required in the file field"})
+			return
+		}
+		upload, err := file.Open()
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "Bad request"})
+			return
+		}
+		defer upload.Close()
+
+		cars, err := readCarsCSV(upload)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		createCarsBatch(ctx, cars)
+	})
+
+	router.GET("/api/cars/batch", func(ctx *gin.Context) {
+		ids := []string{}
+		for _, id := range strings.Split(ctx.Query("ids"), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		idsJSON, _ := json.Marshal(ids)
+		result := submitTxnFn("org1", "autochannel", "KBA-Automobile", "CarContract", "query", make(map[string][]byte), "ReadCars", string(idsJSON))
+
+		ctx.JSON(http.StatusOK, gin.H{"data": result})
+	})
+
+	router.GET("/api/orders", func(ctx *gin.Context) {
+		pageSize, bookmark, ok := pageParams(ctx)
+		if !ok {
+			return
+		}
+		result := submitTxnFn("org1", "autochannel", "KBA-Automobile", "OrderContract", "query", make(map[string][]byte), "GetAllOrdersWithPagination", pageSize, bookmark)
+
+		ctx.JSON(http.StatusOK, gin.H{"data": result})
+	})
+
+	router.GET("/api/orders/range", func(ctx *gin.Context) {
+		pageSize, bookmark, ok := pageParams(ctx)
+		if !ok {
+			return
+		}
+		result := submitTxnFn("org1", "autochannel", "KBA-Automobile", "OrderContract", "query", make(map[string][]byte), "GetOrdersByRangeWithPagination", ctx.Query("startKey"), ctx.Query("endKey"), pageSize, bookmark)
+
+		ctx.JSON(http.StatusOK, gin.H{"data": result})
+	})
+
+	router.GET("/api/orders/status/:status", func(ctx *gin.Context) {
+		status := ctx.Param("status")
+		result := submitTxnFn("org1", "autochannel", "KBA-Automobile", "OrderContract", "query", make(map[string][]byte), "GetOrdersByStatus", status)
+
+		ctx.JSON(http.StatusOK, gin.H{"data": result})
+	})
+
+	router.GET("/api/dealers", func(ctx *gin.Context) {
+		result := submitTxnFn("org1", "autochannel", "KBA-Automobile", "OrderContract", "query", make(map[string][]byte), "GetDealers")
+
+		ctx.JSON(http.StatusOK, gin.H{"data": result})
+	})
+
 	router.Run("localhost:3001")
 }
+
+// pageParams reads the pageSize and bookmark query parameters of a listing
+// request. It writes a bad request response and returns false when pageSize
+// is not a positive number.
+func pageParams(ctx *gin.Context) (string, string, bool) {
+	pageSize := ctx.DefaultQuery("pageSize", defaultPageSize)
+	if size, err := strconv.Atoi(pageSize); err != nil || size < 1 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "pageSize must be a positive number"})
+		return "", "", false
+	}
+	return pageSize, ctx.Query("bookmark"), true
+}
+
+// createCarsBatch submits the cars in a single CreateCarsBatch transaction
+func createCarsBatch(ctx *gin.Context, cars []Car) {
+	if len(cars) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "at least one car is required"})
+		return
+	}
+	carsJSON, err := json.Marshal(cars)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "Bad request"})
+		return
+	}
+	result := submitTxnFn("org1", "autochannel", "KBA-Automobile", "CarContract", "invoke", make(map[string][]byte), "CreateCarsBatch", string(carsJSON))
+
+	ctx.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// carCSVColumns are the columns expected in the header of a car CSV upload
+var carCSVColumns = []string{"carId", "make", "model", "color", "dateOfManufacture", "manufacturerName"}
+
+// readCarsCSV reads the cars of a CSV upload. The first line is a header
+// naming the columns, in any order.
+func readCarsCSV(r io.Reader) ([]Car, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CSV header: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range carCSVColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the CSV header is missing the %s column", name)
+		}
+	}
+
+	var cars []Car
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CSV: %v", err)
+		}
+		cars = append(cars, Car{
+			CarId:        record[columns["carId"]],
+			Make:         record[columns["make"]],
+			Model:        record[columns["model"]],
+			Color:        record[columns["color"]],
+			Date:         record[columns["dateOfManufacture"]],
+			Manufacturer: record[columns["manufacturerName"]],
+		})
+	}
+	return cars, nil
+}
@@ -0,0 +1,3 @@
+module couchquery
+
+go 1.24.4
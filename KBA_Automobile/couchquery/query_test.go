@@ -0,0 +1,129 @@
+package couchquery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() *Builder
+		want  string
+		err   string
+	}{
+		{
+			name:  "equality",
+			build: func() *Builder { return New().Eq("location", "Kochi") },
+			want:  `{"selector":{"location":{"$eq":"Kochi"}}}`,
+		},
+		{
+			name:  "quotes stay inside the value",
+			build: func() *Builder { return New().Eq("location", `x"},"owner":{"$gt":null}`) },
+			want:  `{"selector":{"location":{"$eq":"x\"},\"owner\":{\"$gt\":null}"}}}`,
+		},
+		{
+			name: "conditions on one field are combined",
+			build: func() *Builder {
+				return New().Where("modelYear", Gte, 2020).Where("modelYear", Lt, 2024)
+			},
+			want: `{"selector":{"modelYear":{"$gte":2020,"$lt":2024}}}`,
+		},
+		{
+			name: "sort, fields and limit",
+			build: func() *Builder {
+				return New().Eq("assetType", "car").Sort("carId", true).Fields("carId", "make").Limit(10)
+			},
+			want: `{"selector":{"assetType":{"$eq":"car"}},"sort":[{"carId":"desc"}],"fields":["carId","make"],"limit":10}`,
+		},
+		{
+			name:  "lists for $in",
+			build: func() *Builder { return New().Where("color", In, []string{"Red", "Blue"}) },
+			want:  `{"selector":{"color":{"$in":["Red","Blue"]}}}`,
+		},
+		{
+			name:  "nested field",
+			build: func() *Builder { return New().Where("lien.lender.name", Exists, true) },
+			want:  `{"selector":{"lien.lender.name":{"$exists":true}}}`,
+		},
+		{
+			name:  "no condition",
+			build: func() *Builder { return New().Sort("carId", false) },
+			err:   "at least one condition",
+		},
+		{
+			name:  "unsupported operator",
+			build: func() *Builder { return New().Where("make", "$regex", ".*") },
+			err:   `unsupported query operator "$regex"`,
+		},
+		{
+			name:  "operator as field",
+			build: func() *Builder { return New().Eq("$or", "x") },
+			err:   `invalid query field "$or"`,
+		},
+		{
+			name:  "object value",
+			build: func() *Builder { return New().Eq("make", map[string]interface{}{"$gt": ""}) },
+			err:   "a string, number or boolean is required",
+		},
+		{
+			name:  "object in a list",
+			build: func() *Builder { return New().Where("make", In, []interface{}{"a", map[string]interface{}{}}) },
+			err:   "list entries must be strings, numbers or booleans",
+		},
+		{
+			name:  "scalar for $in",
+			build: func() *Builder { return New().Where("make", In, "a") },
+			err:   "a list is required",
+		},
+		{
+			name:  "non-boolean $exists",
+			build: func() *Builder { return New().Where("make", Exists, "yes") },
+			err:   "true or false is required",
+		},
+		{
+			name:  "repeated condition",
+			build: func() *Builder { return New().Eq("make", "a").Eq("make", "b") },
+			err:   "given twice",
+		},
+		{
+			name:  "invalid sort field",
+			build: func() *Builder { return New().Eq("make", "a").Sort("make desc", false) },
+			err:   "invalid query field",
+		},
+		{
+			name:  "limit too large",
+			build: func() *Builder { return New().Eq("make", "a").Limit(MaxLimit + 1) },
+			err:   "limit must be between 1 and",
+		},
+		{
+			name:  "first error is kept",
+			build: func() *Builder { return New().Eq("$a", "x").Where("b", "$c", "y") },
+			err:   `invalid query field "$a"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.build().Build()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Build() = %s, %v, want an error containing %q", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Build() = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Build() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHas(t *testing.T) {
+	b := New().Eq("make", "Maruti")
+	if !b.Has("make") || b.Has("status") {
+		t.Fatalf("Has reports the wrong fields")
+	}
+}
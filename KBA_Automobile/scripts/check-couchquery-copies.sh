@@ -0,0 +1,14 @@
+#!/bin/sh
+# Fails when a chaincode carries an outdated copy of the couchquery package.
+# The automobile chaincode vendors it, so run `go mod vendor` there; the rice
+# chaincode keeps a plain copy, so copy query.go over it.
+cd "$(dirname "$0")/../couchquery" || exit 1
+
+status=0
+for copy in ../Chaincode/vendor/couchquery/query.go ../Rice_Supplychain/chaincode/rice/couchquery/query.go; do
+	if ! cmp -s query.go "$copy"; then
+		echo "$copy differs from couchquery/query.go" >&2
+		status=1
+	fi
+done
+exit $status